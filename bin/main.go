@@ -2,190 +2,198 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
+	"time"
 
 	"github.com/JamisonHubbard/dsbeyond/model"
 	"github.com/JamisonHubbard/dsbeyond/rules"
 )
 
 func main() {
-	// mock character and decision data
-	character := model.Character{
-		ID:      "test_character",
-		ClassID: "censor",
-		Name:    "Arjhan",
-		Level:   10,
-	}
-	decisions := map[string]rules.Decision{
-		"starting_characteristics": {
-			ChoiceID: "starting_characteristics",
-			OptionID: "an1r2in1",
-		},
-		"basic_skill_1": {
-			ChoiceID: "basic_skill_1",
-			RefID:    "brag",
-		},
-		"basic_skill_2": {
-			ChoiceID: "basic_skill_2",
-			RefID:    "history",
-		},
-		"censor_order": {
-			ChoiceID: "censor_order",
-			OptionID: "exorcist",
-		},
-		"deity": {
-			ChoiceID: "deity",
-			Value: rules.ValueRef{
-				Type:  rules.ValueRefTypeString,
-				Value: "Kurtulmak",
-			},
-		},
-		"domain": {
-			ChoiceID: "domain",
-			RefID:    "war",
-		},
-		"kit": {
-			ChoiceID: "kit",
-			RefID:    "dual_wielder",
-		},
-		"level_one_signature_ability": {
-			ChoiceID: "level_one_signature_ability",
-			OptionID: "every_step_death",
-		},
-		"level_one_3_wrath_ability": {
-			ChoiceID: "level_one_3_wrath_ability",
-			OptionID: "behold_a_shield_of_faith",
-		},
-		"level_one_5_wrath_ability": {
-			ChoiceID: "level_one_5_wrath_ability",
-			OptionID: "arrest",
-		},
-		"level_two_perk": {
-			ChoiceID: "level_two_perk",
-			RefID:    "brawny",
-		},
-		"level_two_exorcist_order_ability": {
-			ChoiceID: "level_two_exorcist_order_ability",
-			OptionID: "it_is_justice_you_fear",
-		},
-		"level_three_7_wrath_ability": {
-			ChoiceID: "level_three_7_wrath_ability",
-			OptionID: "edict_of_stillness",
-		},
-		"level_four_perk": {
-			ChoiceID: "level_four_perk",
-			RefID:    "camoflauge_hunter",
-		},
-		"level_four_skill": {
-			ChoiceID: "level_four_skill",
-			RefID:    "magic",
-		},
-		"level_five_9_wrath_ability": {
-			ChoiceID: "level_five_9_wrath_ability",
-			OptionID: "gods_grant_thee_strength",
-		},
-		"level_six_perk": {
-			ChoiceID: "level_four_perk",
-			RefID:    "danger_sense",
-		},
-		"level_six_exorcist_order_ability": {
-			ChoiceID: "level_six_perk",
-			OptionID: "pain_of_your_own_making",
-		},
-		"level_seven_skill": {
-			ChoiceID: "level_seven_skill",
-			RefID:    "handle_animals",
-		},
-		"level_eight_perk": {
-			ChoiceID: "level_eight_perk",
-			RefID:    "friend_catapult",
-		},
-		"level_eight_11_wrath_ability": {
-			ChoiceID: "level_eight_11_wrath_ability",
-			OptionID: "your_allies_turn_on_you",
-		},
-		"level_nine_exorcist_order_ability": {
-			ChoiceID: "level_nine_exorcist_order_ability",
-			OptionID: "terror_manifest",
-		},
-		"level_ten_perk": {
-			ChoiceID: "level_ten_perk",
-			RefID:    "ive_got_you",
-		},
-		"level_ten_skill": {
-			ChoiceID: "level_ten_skill",
-			RefID:    "lie",
-		},
+	dataDir := flag.String("data", "data", "directory containing the reference data")
+	fixturePath := flag.String("character", "fixtures/arjhan.json", "fixture containing the character and decisions to resolve")
+	watch := flag.Bool("watch", false, "poll the data directory and re-resolve the fixtures on every change")
+	fixturesDir := flag.String("fixtures", "fixtures", "directory of fixtures to re-resolve in watch mode")
+	interval := flag.Duration("interval", time.Second, "how often to poll the data directory in watch mode")
+	flag.Parse()
+
+	if *watch {
+		watchData(*dataDir, *fixturesDir, *interval)
+		return
+	}
+
+	// load character and decision data
+	fixture, err := loadFixture(*fixturePath)
+	if err != nil {
+		fmt.Println("ERROR failed to load fixture: " + err.Error())
+		return
 	}
 
 	// load reference data, e.g. skills and abilities
-	reference, err := loadReference()
+	reference, err := loadReference(*dataDir)
 	if err != nil {
 		fmt.Println("ERROR failed to load reference: " + err.Error())
 		return
 	}
 
-	resolver := rules.NewResolver(character, decisions, &reference)
+	if errs := reference.Validate(); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Println("ERROR invalid reference: " + err.Error())
+		}
+		return
+	}
+
+	if errs := reference.ValidateDecisions(fixture.Character, fixture.Decisions); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Println("ERROR invalid decisions: " + err.Error())
+		}
+		return
+	}
+
+	resolver := rules.NewResolver(fixture.Character, fixture.Decisions, &reference)
 	sheet, err := resolver.Resolve()
 	if err != nil {
 		fmt.Println("ERROR failed to resolve: " + err.Error())
 		return
 	}
-	sheet.CharacterID = character.ID
-	sheet.ClassID = character.ClassID
-	sheet.Level = character.Level
-
 	sheetPretty, err := json.MarshalIndent(sheet, "", "  ")
 	if err != nil {
 		fmt.Println("ERROR " + err.Error())
-		fmt.Println(character)
+		fmt.Println(fixture.Character)
 		return
 	}
 
 	fmt.Println(string(sheetPretty))
 }
 
-func loadReference() (rules.Reference, error) {
-	abilities, err := loadArraysFromFolder[rules.Ability]("data/abilities")
+// A Fixture is a character and the decisions made for it, stored as JSON
+type Fixture struct {
+	Character model.Character           `json:"character"`
+	Decisions map[string]rules.Decision `json:"decisions"`
+}
+
+func loadFixture(path string) (Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fixture{}, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return Fixture{}, fmt.Errorf("failed to unmarshal %s: %s", path, err)
+	}
+
+	return fixture, nil
+}
+
+func loadReference(root string) (rules.Reference, error) {
+	abilities, err := loadArraysFromFolder[rules.Ability](filepath.Join(root, "abilities"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	ancestries, err := loadArrayFromFolder[rules.Ancestry](filepath.Join(root, "ancestries"))
 	if err != nil {
 		return rules.Reference{}, err
 	}
 
-	classes, err := loadArrayFromFolder[rules.Class]("data/classes")
+	careers, err := loadArrayFromFile[rules.Career](filepath.Join(root, "careers.json"))
 	if err != nil {
 		return rules.Reference{}, err
 	}
 
-	domains, err := loadArrayFromFile[rules.Domain]("data/domains.json")
+	classes, err := loadArrayFromFolder[rules.Class](filepath.Join(root, "classes"))
 	if err != nil {
 		return rules.Reference{}, err
 	}
 
-	features, err := loadArraysFromFolder[rules.Feature]("data/features")
+	complications, err := loadArrayFromFile[rules.Complication](filepath.Join(root, "complications.json"))
 	if err != nil {
 		return rules.Reference{}, err
 	}
 
-	kits, err := loadArrayFromFile[rules.Kit]("data/kits.json")
+	cultures, err := loadArrayFromFile[rules.Culture](filepath.Join(root, "cultures.json"))
 	if err != nil {
 		return rules.Reference{}, err
 	}
 
-	skills, err := loadArrayFromFile[rules.Skill]("data/skills.json")
+	domains, err := loadArrayFromFile[rules.Domain](filepath.Join(root, "domains.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	features, err := loadArraysFromFolder[rules.Feature](filepath.Join(root, "features"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	heroicResources, err := loadArrayFromFile[rules.HeroicResource](filepath.Join(root, "heroic_resources.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	kits, err := loadArrayFromFile[rules.Kit](filepath.Join(root, "kits.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	languages, err := loadArrayFromFile[rules.Language](filepath.Join(root, "languages.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	resources, err := loadArrayFromFile[rules.Resource](filepath.Join(root, "resources.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	skills, err := loadArrayFromFile[rules.Skill](filepath.Join(root, "skills.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	skillGroups, err := loadArrayFromFile[rules.SkillGroup](filepath.Join(root, "skill_groups.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	titles, err := loadArrayFromFile[rules.Title](filepath.Join(root, "titles.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	treasures, err := loadArrayFromFile[rules.Treasure](filepath.Join(root, "treasures.json"))
+	if err != nil {
+		return rules.Reference{}, err
+	}
+
+	leveling, err := loadObjectFromFile[rules.Leveling](filepath.Join(root, "leveling.json"))
 	if err != nil {
 		return rules.Reference{}, err
 	}
 
 	reference := rules.Reference{
-		Abilities: abilities,
-		Classes:   classes,
-		Domains:   domains,
-		Features:  features,
-		Kits:      kits,
-		Skills:    skills,
+		Abilities:       abilities,
+		Ancestries:      ancestries,
+		Careers:         careers,
+		Classes:         classes,
+		Complications:   complications,
+		Cultures:        cultures,
+		Domains:         domains,
+		Features:        features,
+		HeroicResources: heroicResources,
+		Kits:            kits,
+		Languages:       languages,
+		Resources:       resources,
+		Skills:          skills,
+		SkillGroups:     skillGroups,
+		Titles:          titles,
+		Treasures:       treasures,
+		Leveling:        leveling,
 	}
 
 	// referencePretty, err := json.MarshalIndent(reference, "", "  ")
@@ -199,14 +207,40 @@ func loadReference() (rules.Reference, error) {
 }
 
 type ItemT interface {
-	rules.Skill |
+	rules.Ancestry |
+		rules.Career |
+		rules.Complication |
+		rules.Culture |
+		rules.Skill |
+		rules.SkillGroup |
+		rules.Title |
+		rules.Treasure |
 		rules.Class |
 		rules.Domain |
 		rules.Ability |
+		rules.HeroicResource |
 		rules.Kit |
+		rules.Language |
+		rules.Resource |
 		rules.Feature
 }
 
+// loadObjectFromFile reads a file holding a single JSON object with no ID
+func loadObjectFromFile[T any](path string) (T, error) {
+	var object T
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return object, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+
+	if err := json.Unmarshal(data, &object); err != nil {
+		return object, fmt.Errorf("failed to unmarshal %s: %s", path, err)
+	}
+
+	return object, nil
+}
+
 func loadArrayFromFile[T ItemT](path string) (map[string]T, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/JamisonHubbard/dsbeyond/rules"
+)
+
+// resolving the same fixture must always give the same sheet, however the
+// resolver happens to walk its nodes
+func TestResolveDeterministic(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	reference, err := loadReference(filepath.Join("..", "data"))
+	if err != nil {
+		t.Fatalf("failed to load reference: %s", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join("..", "fixtures", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			fixture, err := loadFixture(path)
+			if err != nil {
+				t.Fatalf("failed to load fixture: %s", err)
+			}
+
+			var first []byte
+			for i := 0; i < 40; i++ {
+				resolver := rules.NewResolver(fixture.Character, fixture.Decisions, &reference)
+				sheet, err := resolver.Resolve()
+				if err != nil {
+					t.Fatalf("failed to resolve: %s", err)
+				}
+				data, err := json.Marshal(sheet)
+				if err != nil {
+					t.Fatalf("failed to marshal sheet: %s", err)
+				}
+
+				if first == nil {
+					first = data
+					continue
+				}
+				if !bytes.Equal(first, data) {
+					t.Fatalf("resolve %d gave a different sheet:\n%s\nfirst:\n%s", i, data, first)
+				}
+			}
+		})
+	}
+}
+
+// every entity in the data must come back unchanged after being marshalled and
+// unmarshalled, so the custom JSON handling of types like ValueRef and
+// Operation doesn't lose anything
+func TestReferenceRoundTrip(t *testing.T) {
+	reference, err := loadReference(filepath.Join("..", "data"))
+	if err != nil {
+		t.Fatalf("failed to load reference: %s", err)
+	}
+
+	fields := reflect.ValueOf(reference)
+	for i := 0; i < fields.NumField(); i++ {
+		name := fields.Type().Field(i).Name
+		field := fields.Field(i)
+
+		// unexported fields are caches, not data
+		if !fields.Type().Field(i).IsExported() {
+			continue
+		}
+
+		// the entity maps are checked per entity, anything else as a whole
+		if field.Kind() != reflect.Map {
+			checkRoundTrip(t, name, field)
+			continue
+		}
+		iter := field.MapRange()
+		for iter.Next() {
+			checkRoundTrip(t, fmt.Sprintf("%s[%s]", name, iter.Key()), iter.Value())
+		}
+	}
+}
+
+func checkRoundTrip(t *testing.T, name string, value reflect.Value) {
+	t.Helper()
+
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		t.Errorf("%s: failed to marshal: %s", name, err)
+		return
+	}
+
+	decoded := reflect.New(value.Type())
+	if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+		t.Errorf("%s: failed to unmarshal: %s", name, err)
+		return
+	}
+
+	if !reflect.DeepEqual(value.Interface(), decoded.Elem().Interface()) {
+		again, _ := json.Marshal(decoded.Interface())
+		t.Errorf("%s: changed after a round trip:\n%s\nbecame:\n%s", name, data, again)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/JamisonHubbard/dsbeyond/schema"
+)
+
+func main() {
+	out := flag.String("out", "schemas", "directory to write the generated schemas to")
+	validate := flag.String("validate", "", "data directory to validate instead of generating schemas")
+	flag.Parse()
+
+	if *validate != "" {
+		errs, err := schema.ValidateDir(*validate)
+		if err != nil {
+			fmt.Println("ERROR failed to validate: " + err.Error())
+			os.Exit(1)
+		}
+		for _, err := range errs {
+			fmt.Println(err.Error())
+		}
+		if len(errs) > 0 {
+			fmt.Printf("%d validation errors\n", len(errs))
+			os.Exit(1)
+		}
+		fmt.Println("data is valid")
+		return
+	}
+
+	documents := schema.Generate()
+	for _, dataFile := range schema.DataFiles {
+		if dataFile.Array {
+			documents[dataFile.Type+"List"] = dataFile.FileDocument()
+		}
+	}
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fmt.Println("ERROR " + err.Error())
+		os.Exit(1)
+	}
+
+	for name, document := range documents {
+		data, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			fmt.Println("ERROR failed to marshal " + name + ": " + err.Error())
+			os.Exit(1)
+		}
+
+		path := filepath.Join(*out, schema.FileName(name))
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			fmt.Println("ERROR " + err.Error())
+			os.Exit(1)
+		}
+	}
+}
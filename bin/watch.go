@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JamisonHubbard/dsbeyond/model"
+	"github.com/JamisonHubbard/dsbeyond/rules"
+	"github.com/JamisonHubbard/dsbeyond/schema"
+)
+
+// watchData polls the data directory and, on every change, reloads the
+// reference, re-resolves each fixture and prints what changed since the
+// previous run
+func watchData(dataDir string, fixturesDir string, interval time.Duration) {
+	// the resolver logs every operation, which drowns out the diffs
+	log.SetOutput(io.Discard)
+
+	w := watcher{
+		dataDir:     dataDir,
+		fixturesDir: fixturesDir,
+		sheets:      make(map[string]map[string]string),
+	}
+
+	var snapshot map[string]fileState
+	for {
+		current, err := snapshotDir(dataDir)
+		if err != nil {
+			fmt.Println("ERROR failed to read data directory: " + err.Error())
+		} else if !sameSnapshot(snapshot, current) {
+			if snapshot != nil {
+				fmt.Printf("\n=== %s: data changed\n", time.Now().Format(time.TimeOnly))
+				for _, path := range changedFiles(snapshot, current) {
+					fmt.Println("  " + path)
+				}
+			}
+			snapshot = current
+			w.runRecovered()
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshotDir records the modification time and size of each JSON file below
+// root
+func snapshotDir(root string) (map[string]fileState, error) {
+	snapshot := make(map[string]fileState)
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		snapshot[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return snapshot, err
+}
+
+// sameSnapshot reports whether two snapshots match, treating a missing
+// previous snapshot as a change
+func sameSnapshot(a map[string]fileState, b map[string]fileState) bool {
+	if a == nil || len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if b[path] != state {
+			return false
+		}
+	}
+	return true
+}
+
+func changedFiles(previous map[string]fileState, current map[string]fileState) []string {
+	var paths []string
+	for path, state := range current {
+		if previous[path] != state {
+			paths = append(paths, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			paths = append(paths, path+" (removed)")
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+type watcher struct {
+	dataDir     string
+	fixturesDir string
+
+	// results of the previous run
+	validation []string
+	sheets     map[string]map[string]string
+}
+
+// runRecovered runs a cycle, reporting a panic from bad data instead of
+// stopping the watch so the next change can fix it
+func (w *watcher) runRecovered() {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			fmt.Printf("ERROR re-resolve failed: %v\n", recovered)
+		}
+	}()
+	w.run()
+}
+
+// run performs a single reload and prints the differences from the last run
+func (w *watcher) run() {
+	// report validation errors that weren't present last time
+	validationErrs, err := schema.ValidateDir(w.dataDir)
+	if err != nil {
+		fmt.Println("ERROR failed to validate: " + err.Error())
+	}
+	var validation []string
+	for _, validationErr := range validationErrs {
+		validation = append(validation, validationErr.Error())
+	}
+
+	reference, err := loadReference(w.dataDir)
+	if err != nil {
+		fmt.Println("ERROR failed to load reference: " + err.Error())
+		w.reportValidation(validation)
+		return
+	}
+	for _, referenceErr := range reference.Validate() {
+		validation = append(validation, referenceErr.Error())
+	}
+	w.reportValidation(validation)
+
+	fixturePaths, err := filepath.Glob(filepath.Join(w.fixturesDir, "*.json"))
+	if err != nil {
+		fmt.Println("ERROR failed to list fixtures: " + err.Error())
+		return
+	}
+
+	for _, path := range fixturePaths {
+		fixture, err := loadFixture(path)
+		if err != nil {
+			fmt.Println("ERROR failed to load fixture: " + err.Error())
+			continue
+		}
+
+		if errs := reference.ValidateDecisions(fixture.Character, fixture.Decisions); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Printf("ERROR invalid decisions in %s: %s\n", path, err)
+			}
+			continue
+		}
+
+		resolver := rules.NewResolver(fixture.Character, fixture.Decisions, &reference)
+		sheet, err := resolver.Resolve()
+		if err != nil {
+			fmt.Printf("ERROR failed to resolve %s: %s\n", path, err)
+			continue
+		}
+
+		flat, err := flattenSheet(&sheet)
+		if err != nil {
+			fmt.Printf("ERROR failed to flatten %s: %s\n", path, err)
+			continue
+		}
+
+		previous, ok := w.sheets[path]
+		w.sheets[path] = flat
+		if !ok {
+			fmt.Printf("%s: resolved %s\n", path, fixture.Character.Name)
+			continue
+		}
+
+		diff := diffSheets(previous, flat)
+		if len(diff) == 0 {
+			fmt.Printf("%s: no changes\n", path)
+			continue
+		}
+		fmt.Printf("%s:\n", path)
+		for _, line := range diff {
+			fmt.Println("  " + line)
+		}
+	}
+}
+
+// reportValidation prints the validation errors that weren't present in the
+// previous run
+func (w *watcher) reportValidation(validation []string) {
+	for _, message := range validation {
+		if !slices.Contains(w.validation, message) {
+			fmt.Println("NEW VALIDATION ERROR " + message)
+		}
+	}
+	w.validation = validation
+}
+
+// flattenSheet converts a sheet into dotted paths mapped to their values. The
+// resolver doesn't guarantee list order, so list entries are flattened into
+// their own paths and compared as sets.
+func flattenSheet(sheet *model.Sheet) (map[string]string, error) {
+	// the hashes always change with the data and aren't useful in a diff
+	stripped := *sheet
+	stripped.ReferenceHash = ""
+	stripped.EntityHashes = nil
+
+	data, err := json.Marshal(stripped)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	flat := make(map[string]string)
+	flattenValue("", values, flat)
+	return flat, nil
+}
+
+func flattenValue(prefix string, value any, flat map[string]string) {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, child := range value {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenValue(path, child, flat)
+		}
+	case []any:
+		for _, child := range value {
+			data, _ := json.Marshal(child)
+			flat[prefix+"[] "+string(data)] = ""
+		}
+	default:
+		data, _ := json.Marshal(value)
+		flat[prefix] = string(data)
+	}
+}
+
+// diffSheets lists added, removed and changed paths between two flattened
+// sheets
+func diffSheets(previous map[string]string, current map[string]string) []string {
+	var lines []string
+	for path, value := range current {
+		old, ok := previous[path]
+		switch {
+		case !ok:
+			lines = append(lines, strings.TrimSpace("+ "+path+" "+value))
+		case old != value:
+			lines = append(lines, fmt.Sprintf("~ %s %s -> %s", path, old, value))
+		}
+	}
+	for path, value := range previous {
+		if _, ok := current[path]; !ok {
+			lines = append(lines, strings.TrimSpace("- "+path+" "+value))
+		}
+	}
+
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	return lines
+}
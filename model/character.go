@@ -1,9 +1,42 @@
 package model
 
+// leveling modes decide whether a character's XP limits their level
+const (
+	// LevelingManual leaves the level up to the director, for milestone
+	// campaigns
+	LevelingManual = "manual"
+	// LevelingXP limits the level to the one earned with the character's XP
+	LevelingXP = "xp"
+)
+
 type Character struct {
-	ID      string `json:"id"`
-	ClassID string `json:"class_id"`
+	ID         string `json:"id"`
+	ClassID    string `json:"class_id"`
+	AncestryID string `json:"ancestry_id"`
+	CultureID  string `json:"culture_id"`
+	CareerID   string `json:"career_id"`
 	// UserID string `json:"user_id"`
 	Name  string `json:"name"`
 	Level int    `json:"level"`
+	// XP and Victories are earned during play, the XP deciding the level the
+	// character can reach unless Leveling is LevelingManual
+	XP        int           `json:"xp"`
+	Victories int           `json:"victories"`
+	Leveling  string        `json:"leveling"`
+	Inventory []Item        `json:"inventory"`
+	Titles    []EarnedTitle `json:"titles"`
+}
+
+// An EarnedTitle is a title the character has earned and the benefit they
+// picked for it
+type EarnedTitle struct {
+	TitleID   string `json:"title_id"`
+	BenefitID string `json:"benefit_id"`
+}
+
+// An Item is a treasure the character carries
+type Item struct {
+	TreasureID string `json:"treasure_id"`
+	Quantity   int    `json:"quantity"`
+	Equipped   bool   `json:"equipped"`
 }
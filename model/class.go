@@ -0,0 +1,71 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CensorSheet is the censor specific part of a character sheet
+type CensorSheet struct {
+	Order  string `json:"order"`
+	Deity  string `json:"deity"`
+	Domain string `json:"domain"`
+}
+
+// ClassSheets maps a class ID to a constructor for the class specific part of
+// a character sheet
+var ClassSheets = map[string]func() any{
+	"censor": func() any { return &CensorSheet{} },
+}
+
+// NewClassSheet returns an empty class specific sheet for the class, which is
+// a pointer to one of the types in ClassSheets, or nil if the class has none
+func NewClassSheet(classID string) any {
+	newSheet, ok := ClassSheets[classID]
+	if !ok {
+		return nil
+	}
+	return newSheet()
+}
+
+// UnmarshalJSON is a custom unmarshaller for Sheet that decodes the class
+// block into the typed class sheet for the sheet's class. A sheet without a
+// class, or for a class with no class sheet, keeps the class block as a plain
+// map.
+func (s *Sheet) UnmarshalJSON(data []byte) error {
+	// the alias drops the methods of Sheet so decoding doesn't recurse
+	type sheetAlias Sheet
+	var tmp struct {
+		sheetAlias
+		Class json.RawMessage `json:"class"`
+	}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+
+	*s = Sheet(tmp.sheetAlias)
+	hasClass := len(tmp.Class) > 0 && string(tmp.Class) != "null"
+
+	class := NewClassSheet(s.ClassID)
+	if class == nil {
+		var raw map[string]any
+		if hasClass {
+			if err := json.Unmarshal(tmp.Class, &raw); err != nil {
+				return fmt.Errorf("failed to unmarshal class block: %w", err)
+			}
+		}
+		if raw != nil {
+			s.Class = raw
+		}
+		return nil
+	}
+
+	if hasClass {
+		if err := json.Unmarshal(tmp.Class, class); err != nil {
+			return fmt.Errorf("failed to unmarshal class sheet: %w", err)
+		}
+	}
+	s.Class = class
+
+	return nil
+}
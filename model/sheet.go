@@ -1,21 +1,61 @@
 package model
 
 type Sheet struct {
-	CharacterID      string          `json:"character_id"`
-	ClassID          string          `json:"class_id"`
-	Level            int             `json:"level"`
+	CharacterID    string `json:"character_id"`
+	ClassID        string `json:"class_id"`
+	AncestryID     string `json:"ancestry_id"`
+	CultureID      string `json:"culture_id"`
+	CareerID       string `json:"career_id"`
+	ComplicationID string `json:"complication_id"`
+	Level          int    `json:"level"`
+	XP             int    `json:"xp"`
+	Victories      int    `json:"victories"`
+	Leveling       string `json:"leveling"`
+	// LevelUp is set when the character's XP has earned a level they haven't
+	// taken yet
+	LevelUp          *LevelUp        `json:"level_up"`
 	HeroicResource   string          `json:"heroic_resource"`
 	Characteristics  Characteristics `json:"characteristics"`
 	Health           Health          `json:"health"`
 	Movement         Movement        `json:"movement"`
 	Potencies        Potencies       `json:"potencies"`
+	DamageBonuses    DamageBonuses   `json:"damage_bonuses"`
+	Saves            Saves           `json:"saves"`
+	Surges           Surges          `json:"surges"`
 	Abilities        []string        `json:"abilities"`
 	AbilityModifiers []string        `json:"ability_modifiers"`
 	Domains          []string        `json:"domains"`
 	Features         []string        `json:"features"`
-	Kits             []string        `json:"kits"`
-	Skills           []string        `json:"skills"`
-	Class            map[string]any  `json:"class"`
+	// SupersededFeatures maps each feature replaced by an upgrade to the
+	// feature that replaced it
+	SupersededFeatures map[string]string `json:"superseded_features"`
+	Kits               []string          `json:"kits"`
+	Inventory          []Item            `json:"inventory"`
+	Titles             []string          `json:"titles"`
+	Languages          []string          `json:"languages"`
+	// Resources lists the extra resources the hero has besides their heroic
+	// resource
+	Resources []string `json:"resources"`
+	Skills    []string `json:"skills"`
+	// Immunities and Weaknesses map a damage type to its value
+	Immunities map[string]int `json:"immunities"`
+	Weaknesses map[string]int `json:"weaknesses"`
+	// Edges and Banes list the skills whose tests are made with an edge or a
+	// bane
+	Edges            []string `json:"edges"`
+	Banes            []string `json:"banes"`
+	Renown           int      `json:"renown"`
+	Wealth           int      `json:"wealth"`
+	ProjectPoints    int      `json:"project_points"`
+	IncitingIncident string   `json:"inciting_incident"`
+	// Class is the class specific part of the sheet, one of the types in
+	// ClassSheets, or the plain class block for a class without one
+	Class any `json:"class"`
+
+	// hashes of the reference data the sheet was resolved against, used to
+	// detect when a stored sheet has drifted from the data
+	ReferenceHash string            `json:"reference_hash"`
+	EntityHashes  map[string]string `json:"entity_hashes"`
 }
 
 type Characteristics struct {
@@ -29,13 +69,66 @@ type Characteristics struct {
 type Health struct {
 	MaxStamina    int `json:"max_stamina"`
 	MaxRecoveries int `json:"max_recoveries"`
+	// RecoveryValue is the stamina regained by spending a recovery
+	RecoveryValue int `json:"recovery_value"`
+	// WindedValue is the stamina at or below which a hero is winded
+	WindedValue int `json:"winded_value"`
+	// DyingValue is the negative stamina at or below which a dying hero dies
+	DyingValue int `json:"dying_value"`
 }
 
 type Movement struct {
-	Size      string `json:"size"`
-	Speed     int    `json:"speed"`
-	Stability int    `json:"stability"`
-	Disengage int    `json:"disengage"`
+	Size      Size `json:"size"`
+	Speed     int  `json:"speed"`
+	Stability int  `json:"stability"`
+	Disengage int  `json:"disengage"`
+	// Modes maps each extra way the hero can move, like flying or climbing, to
+	// its speed
+	Modes map[string]int `json:"modes"`
+}
+
+// A LevelUp is the levels a character has earned but not yet taken
+type LevelUp struct {
+	// Level is the highest level earned
+	Level int `json:"level"`
+	// Choices lists the IDs of the class choices the new levels unlock
+	Choices []string `json:"choices"`
+}
+
+// Saves describe the hero's saving throws
+type Saves struct {
+	// Target is the lowest d10 roll that succeeds on a saving throw, before
+	// bonuses
+	Target int `json:"target"`
+	// Bonuses maps the source of each bonus to saving throws, like a feature
+	// ID, to its value
+	Bonuses map[string]int `json:"bonuses"`
+}
+
+// Bonus is the total of every bonus to the hero's saving throws
+func (s Saves) Bonus() int {
+	var total int
+	for _, bonus := range s.Bonuses {
+		total += bonus
+	}
+	return total
+}
+
+// Surges describe the surges the hero spends and grants
+type Surges struct {
+	// Damage is the extra damage each surge spent adds, which is the hero's
+	// highest characteristic
+	Damage int `json:"damage"`
+	// Grants maps each ability or feature that gives surges to the number of
+	// surges it gives
+	Grants map[string]int `json:"grants"`
+}
+
+// DamageBonuses are added to the rolled damage of melee and ranged weapon
+// abilities
+type DamageBonuses struct {
+	Melee  int `json:"melee"`
+	Ranged int `json:"ranged"`
 }
 
 type Potencies struct {
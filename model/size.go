@@ -0,0 +1,113 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// size letters divide size 1 creatures, from smallest to largest
+const (
+	SizeLetterTiny   = "T"
+	SizeLetterSmall  = "S"
+	SizeLetterMedium = "M"
+	SizeLetterLarge  = "L"
+)
+
+var sizeLetters = []string{SizeLetterTiny, SizeLetterSmall, SizeLetterMedium, SizeLetterLarge}
+
+var sizePattern = regexp.MustCompile(`^([1-9][0-9]*)([TSML]?)$`)
+
+// A Size is the space a creature occupies, written like "1M" or "2". Only
+// size 1 creatures have a letter.
+type Size struct {
+	Value  int
+	Letter string
+}
+
+// ParseSize reads a size written like "1M" or "2"
+func ParseSize(s string) (Size, error) {
+	matches := sizePattern.FindStringSubmatch(s)
+	if matches == nil {
+		return Size{}, fmt.Errorf("invalid size \"%s\"", s)
+	}
+
+	value, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return Size{}, fmt.Errorf("invalid size \"%s\": %w", s, err)
+	}
+	size := Size{Value: value, Letter: matches[2]}
+
+	// the letter is required for size 1 and not allowed for larger sizes
+	if size.Value == 1 && size.Letter == "" {
+		return Size{}, fmt.Errorf("invalid size \"%s\": size 1 needs a letter", s)
+	}
+	if size.Value > 1 && size.Letter != "" {
+		return Size{}, fmt.Errorf("invalid size \"%s\": only size 1 has a letter", s)
+	}
+
+	return size, nil
+}
+
+func (s Size) String() string {
+	if s.Value == 0 {
+		return ""
+	}
+	return strconv.Itoa(s.Value) + s.Letter
+}
+
+// Compare returns -1 if s is smaller than other, 1 if it is larger, and 0 if
+// they are the same size
+func (s Size) Compare(other Size) int {
+	if s.Value != other.Value {
+		if s.Value < other.Value {
+			return -1
+		}
+		return 1
+	}
+
+	letter := slices.Index(sizeLetters, s.Letter)
+	otherLetter := slices.Index(sizeLetters, other.Letter)
+	switch {
+	case letter < otherLetter:
+		return -1
+	case letter > otherLetter:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CanForceMove reports whether a creature of this size can force move a target
+// of the other size with a maneuver like Knockback, which needs the target to
+// be no larger
+func (s Size) CanForceMove(target Size) bool {
+	return s.Compare(target) >= 0
+}
+
+// MarshalJSON writes the size as a string like "1M"
+func (s Size) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON reads a size from a string like "1M", leaving an empty string
+// as the zero Size
+func (s *Size) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	if str == "" {
+		*s = Size{}
+		return nil
+	}
+
+	size, err := ParseSize(str)
+	if err != nil {
+		return err
+	}
+	*s = size
+	return nil
+}
@@ -0,0 +1,129 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Size
+		wantErr bool
+	}{
+		{input: "1T", want: Size{Value: 1, Letter: SizeLetterTiny}},
+		{input: "1M", want: Size{Value: 1, Letter: SizeLetterMedium}},
+		{input: "2", want: Size{Value: 2}},
+		{input: "12", want: Size{Value: 12}},
+		{input: "", wantErr: true},
+		{input: "1", wantErr: true},
+		{input: "2L", wantErr: true},
+		{input: "0", wantErr: true},
+		{input: "01M", wantErr: true},
+		{input: "1X", wantErr: true},
+		{input: "1m", wantErr: true},
+		{input: "M", wantErr: true},
+		{input: "-2", wantErr: true},
+		{input: " 1M", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			size, err := ParseSize(test.input)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", size)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+			if size != test.want {
+				t.Errorf("got %+v, want %+v", size, test.want)
+			}
+			if size.String() != test.input {
+				t.Errorf("written as %q, want %q", size.String(), test.input)
+			}
+		})
+	}
+}
+
+func TestSizeCompare(t *testing.T) {
+	tests := []struct {
+		size         string
+		other        string
+		want         int
+		canForceMove bool
+	}{
+		{size: "1T", other: "1M", want: -1, canForceMove: false},
+		{size: "1M", other: "1T", want: 1, canForceMove: true},
+		{size: "1S", other: "1S", want: 0, canForceMove: true},
+		{size: "1L", other: "2", want: -1, canForceMove: false},
+		{size: "2", other: "1L", want: 1, canForceMove: true},
+		{size: "3", other: "10", want: -1, canForceMove: false},
+		{size: "10", other: "3", want: 1, canForceMove: true},
+		{size: "12", other: "12", want: 0, canForceMove: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.size+" vs "+test.other, func(t *testing.T) {
+			size, err := ParseSize(test.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := ParseSize(test.other)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := size.Compare(other); got != test.want {
+				t.Errorf("Compare is %d, want %d", got, test.want)
+			}
+			if got := size.CanForceMove(other); got != test.canForceMove {
+				t.Errorf("CanForceMove is %t, want %t", got, test.canForceMove)
+			}
+		})
+	}
+}
+
+func TestSizeJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Size
+		wantErr bool
+	}{
+		{input: `"1S"`, want: Size{Value: 1, Letter: SizeLetterSmall}},
+		{input: `"3"`, want: Size{Value: 3}},
+		{input: `""`, want: Size{}},
+		{input: `"3L"`, wantErr: true},
+		{input: `2`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var size Size
+			err := json.Unmarshal([]byte(test.input), &size)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", size)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to unmarshal: %s", err)
+			}
+			if size != test.want {
+				t.Errorf("got %+v, want %+v", size, test.want)
+			}
+
+			data, err := json.Marshal(size)
+			if err != nil {
+				t.Fatalf("failed to marshal: %s", err)
+			}
+			if string(data) != test.input {
+				t.Errorf("marshalled as %s, want %s", data, test.input)
+			}
+		})
+	}
+}
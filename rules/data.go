@@ -1,14 +1,214 @@
 package rules
 
+import "fmt"
+
+const (
+	DamageTypeUntyped    = ""
+	DamageTypeAcid       = "acid"
+	DamageTypeCold       = "cold"
+	DamageTypeCorruption = "corruption"
+	DamageTypeFire       = "fire"
+	DamageTypeHoly       = "holy"
+	DamageTypeLightning  = "lightning"
+	DamageTypePoison     = "poison"
+	DamageTypePsychic    = "psychic"
+	DamageTypeSonic      = "sonic"
+)
+
+// DamageTypes lists every typed damage type, which immunities and weaknesses
+// are granted against
+var DamageTypes = []string{
+	DamageTypeAcid,
+	DamageTypeCold,
+	DamageTypeCorruption,
+	DamageTypeFire,
+	DamageTypeHoly,
+	DamageTypeLightning,
+	DamageTypePoison,
+	DamageTypePsychic,
+	DamageTypeSonic,
+}
+
+// An Ancestry grants its signature traits through Operations, and offers its
+// purchasable traits as a point buy Choice
+type Ancestry struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Operations  []Operation `json:"operations"`
+	Choices     []Choice    `json:"choices"`
+}
+
+// A Culture describes the environment, organization and upbringing a hero grew
+// up in, each offered as a Choice
+type Culture struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Operations  []Operation `json:"operations"`
+	Choices     []Choice    `json:"choices"`
+}
+
+// A Career describes what a hero did before they became a hero
+type Career struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Operations  []Operation `json:"operations"`
+	Choices     []Choice    `json:"choices"`
+}
+
+// A Complication is an optional part of a hero's story that comes with both a
+// Benefit and a Drawback
+type Complication struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Benefit     ComplicationEffect `json:"benefit"`
+	Drawback    ComplicationEffect `json:"drawback"`
+}
+
+// A ComplicationEffect is one side of a Complication
+type ComplicationEffect struct {
+	Description string      `json:"description"`
+	Operations  []Operation `json:"operations"`
+	Choices     []Choice    `json:"choices"`
+}
+
+const (
+	ClassValueTypeInt        = "int"
+	ClassValueTypeString     = "string"
+	ClassValueTypeStringList = "string_list"
+)
+
+const (
+	MovementModeBurrow   = "burrow"
+	MovementModeClimb    = "climb"
+	MovementModeFly      = "fly"
+	MovementModeSwim     = "swim"
+	MovementModeTeleport = "teleport"
+)
+
+// MovementModes lists the ways a hero can move besides walking
+var MovementModes = []string{
+	MovementModeBurrow,
+	MovementModeClimb,
+	MovementModeFly,
+	MovementModeSwim,
+	MovementModeTeleport,
+}
+
+const (
+	CharacteristicMight     = "might"
+	CharacteristicAgility   = "agility"
+	CharacteristicReason    = "reason"
+	CharacteristicIntuition = "intuition"
+	CharacteristicPresence  = "presence"
+)
+
+// Characteristics lists every characteristic
+var Characteristics = []string{
+	CharacteristicMight,
+	CharacteristicAgility,
+	CharacteristicReason,
+	CharacteristicIntuition,
+	CharacteristicPresence,
+}
+
+// CharacteristicCaps is the highest score a characteristic can be increased to
+// in each echelon
+var CharacteristicCaps = map[int]int{
+	1: 2,
+	2: 3,
+	3: 4,
+	4: 5,
+}
+
+// Echelon returns the echelon a hero of the given level is in
+func Echelon(level int) int {
+	return min((level-1)/3+1, 4)
+}
+
+// MaxLevel is the highest level a hero can reach
+const MaxLevel = 10
+
+// Leveling describes how heroes advance
+type Leveling struct {
+	// XPThresholds maps each level to the XP needed to reach it
+	XPThresholds map[int]int `json:"xp_thresholds"`
+}
+
+// LevelForXP returns the highest level a hero with the given XP has earned
+func (l Leveling) LevelForXP(xp int) (int, error) {
+	if len(l.XPThresholds) == 0 {
+		return 0, fmt.Errorf("no xp thresholds")
+	}
+
+	// thresholds increase with level, so stop at the first one not reached
+	level := 0
+	for next := 1; next <= MaxLevel; next++ {
+		threshold, ok := l.XPThresholds[next]
+		if !ok || threshold > xp {
+			break
+		}
+		level = next
+	}
+	if level == 0 {
+		return 0, fmt.Errorf("%d xp doesn't reach any level", xp)
+	}
+	return level, nil
+}
+
 const (
-	DamageTypeUntyped = ""
-	DamageTypeHoly    = "holy"
+	ResetTypeEncounterEnd = "encounter_end"
+	ResetTypeRespite      = "respite"
+	ResetTypeNever        = "never"
 )
 
+// A HeroicResource is the resource a class uses to power its heroic abilities
+type HeroicResource struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// PerTurn is gained at the start of each of the hero's turns in combat,
+	// as a number or a roll like "1d3"
+	PerTurn  string            `json:"per_turn"`
+	Triggers []ResourceTrigger `json:"triggers"`
+	// AllowNegative is true when the resource can be spent below zero
+	AllowNegative bool `json:"allow_negative"`
+	// Reset is when any of the resource that remains is lost
+	Reset string `json:"reset"`
+}
+
+// A ResourceTrigger is an event that gains a hero some of a resource
+type ResourceTrigger struct {
+	Description string `json:"description"`
+	// Gain is a number or a roll like "1d3"
+	Gain string `json:"gain"`
+}
+
+// A Resource is an extra pool of points that only some heroes have, such as
+// fate from the Fate domain
+type Resource struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Gains       []ResourceTrigger `json:"gains"`
+	// Spend describes what the resource can be spent on
+	Spend string `json:"spend"`
+	// SpendAs is a heroic resource this resource can be spent as if it were
+	SpendAs string `json:"spend_as"`
+	// Reset is when any of the resource that remains is lost
+	Reset string `json:"reset"`
+}
+
 type Class struct {
 	ID     string             `json:"id"`
 	Name   string             `json:"name"`
 	Levels map[int]ClassLevel `json:"levels"`
+	// Extension declares the type of each class specific value, which
+	// operations set through "class.<name>" targets
+	Extension map[string]string `json:"extension"`
 }
 
 type ClassLevel struct {
@@ -23,25 +223,44 @@ type Skill struct {
 	Group       string `json:"group"`
 }
 
+type Language struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type SkillGroup struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
 type Domain struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 }
 
 const (
-	FeatureTypeBasic = ""
-	FeatureTypePerk  = "perk"
+	FeatureTypeBasic         = ""
+	FeatureTypeAncestryTrait = "ancestry_trait"
+	FeatureTypePerk          = "perk"
 
 	FeatureSectionTypeText         = "text"
 	FeatureSectionTypeBulletedText = "bulleted_text"
 )
 
 type Feature struct {
-	ID        string           `json:"id"`
-	Name      string           `json:"name"`
-	Type      string           `json:"type"`
-	Sections  []FeatureSection `json:"sections"`
-	Abilities []string         `json:"abilities"`
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Type     string           `json:"type"`
+	Sections []FeatureSection `json:"sections"`
+	// Abilities, Operations and Choices are granted along with the feature
+	Abilities  []string    `json:"abilities"`
+	Operations []Operation `json:"operations"`
+	Choices    []Choice    `json:"choices"`
+	// Supersedes lists the features this one replaces, e.g. an improved
+	// version of an earlier feature. A replaced feature grants nothing.
+	Supersedes []string `json:"supersedes"`
 }
 
 type FeatureSection struct {
@@ -51,6 +270,7 @@ type FeatureSection struct {
 
 const (
 	AbilityTypeStandard  = "basic"
+	AbilityTypeDomain    = "domain"
 	AbilityTypeHeroic    = "heroic"
 	AbilityTypeSignature = "signature"
 
@@ -148,6 +368,10 @@ type PotencyEffect struct {
 	Effect               string `json:"effect"`
 }
 
+const (
+	AbilityModifierTypeAppend = "append"
+)
+
 type AbilityModifier struct {
 	ID       string           `json:"id"`
 	Type     string           `json:"type"`
@@ -175,6 +399,39 @@ const (
 	WeaponAmountSeveral  = "several"
 )
 
+// A Title is earned by a hero during play. A hero with the title picks one of
+// its Benefits, which applies as long as they meet the Prereqs.
+type Title struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Echelon     int         `json:"echelon"`
+	Prereqs     []Assertion `json:"prereqs"`
+	Benefits    []Option    `json:"benefits"`
+}
+
+const (
+	TreasureTypeConsumable       = "consumable"
+	TreasureTypeTrinket          = "trinket"
+	TreasureTypeLeveledArmor     = "leveled_armor"
+	TreasureTypeLeveledImplement = "leveled_implement"
+	TreasureTypeLeveledWeapon    = "leveled_weapon"
+	TreasureTypeArtifact         = "artifact"
+)
+
+// A Treasure is an item a hero can carry. The Operations of a treasure apply
+// while it's equipped, and leveled treasures scale their bonuses with the
+// hero's level through level_table value refs.
+type Treasure struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Type        string      `json:"type"`
+	Echelon     int         `json:"echelon"`
+	Keywords    []string    `json:"keywords"`
+	Operations  []Operation `json:"operations"`
+}
+
 type Kit struct {
 	ID          string       `json:"id"`
 	Name        string       `json:"name"`
@@ -199,14 +456,59 @@ type KitBonuses struct {
 	StaminaBonus        int            `json:"stamina_bonus"`
 	SpeedBonus          int            `json:"speed_bonus"`
 	StabilityBonus      int            `json:"stability_bonus"`
-	MeleeDamageBonus    KitDamageBonus `json:"damage_bonus"`
+	MeleeDamageBonus    KitDamageBonus `json:"melee_damage_bonus"`
 	RangedDamageBonus   KitDamageBonus `json:"ranged_damage_bonus"`
 	RangedDistanceBonus int            `json:"ranged_distance_bonus"`
 	DisengageBonus      int            `json:"disengage_bonus"`
 }
 
+// A KitDamageBonus gives a damage bonus for each echelon, with the 4th
+// echelon keeping the 3rd echelon's bonus
 type KitDamageBonus struct {
 	TierI   int `json:"tier_i"`
 	TierII  int `json:"tier_ii"`
 	TierIII int `json:"tier_iii"`
 }
+
+// valueRef returns the bonus as a level table on the echelon scale
+func (b KitDamageBonus) valueRef() ValueRef {
+	return ValueRef{Type: ValueRefTypeLevelTable, Value: &LevelTable{
+		Scale: LevelTableScaleEchelon,
+		Entries: []LevelTableEntry{
+			{Threshold: 1, Value: ValueRef{Type: ValueRefTypeInt, Value: b.TierI}},
+			{Threshold: 2, Value: ValueRef{Type: ValueRefTypeInt, Value: b.TierII}},
+			{Threshold: 3, Value: ValueRef{Type: ValueRefTypeInt, Value: b.TierIII}},
+		},
+	}}
+}
+
+// walkFeatures calls expand once for each feature the operations can add,
+// then for the features added by the operations expand returns, and so on
+// until no new features are found
+func (r *Reference) walkFeatures(operations []Operation, expand func(feature *Feature) []Operation) error {
+	expanded := make(map[string]bool)
+	pending := operations
+	for len(pending) > 0 {
+		var next []Operation
+		for _, operation := range pending {
+			if operation.Type != OperationTypeAddFeature || operation.ValueRef.Type != ValueRefTypeRefID {
+				continue
+			}
+
+			featureID := operation.ValueRef.Value.(string)
+			if expanded[featureID] {
+				continue
+			}
+			expanded[featureID] = true
+
+			feature, ok := r.Features[featureID]
+			if !ok {
+				return fmt.Errorf("feature \"%s\" not found", featureID)
+			}
+			next = append(next, expand(&feature)...)
+		}
+		pending = next
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package rules
+
+import "testing"
+
+func TestLevelForXP(t *testing.T) {
+	leveling := Leveling{XPThresholds: map[int]int{1: 0, 2: 16, 3: 32, 4: 48}}
+
+	tests := []struct {
+		name    string
+		xp      int
+		want    int
+		wantErr string
+	}{
+		{name: "no xp", xp: 0, want: 1},
+		{name: "below a threshold", xp: 15, want: 1},
+		{name: "on a threshold", xp: 16, want: 2},
+		{name: "between thresholds", xp: 40, want: 3},
+		{name: "past the last threshold", xp: 500, want: 4},
+		{name: "negative xp", xp: -1, wantErr: "doesn't reach any level"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			level, err := leveling.LevelForXP(test.xp)
+			if test.wantErr != "" {
+				expectError(t, err, test.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to find level: %s", err)
+			}
+			if level != test.want {
+				t.Errorf("level is %d, want %d", level, test.want)
+			}
+		})
+	}
+
+	_, err := Leveling{}.LevelForXP(100)
+	expectError(t, err, "no xp thresholds")
+}
@@ -0,0 +1,282 @@
+package rules
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/JamisonHubbard/dsbeyond/model"
+)
+
+// entity key types used for classes, ancestries, careers, complications,
+// cultures and xp thresholds, which are never referenced by a ValueRef and so
+// have no RefIDType
+const (
+	EntityKeyAncestry     = "ancestry"
+	EntityKeyCareer       = "career"
+	EntityKeyClass        = "class"
+	EntityKeyComplication = "complication"
+	EntityKeyCulture      = "culture"
+	EntityKeyXPThreshold  = "xp_threshold"
+)
+
+// EntityKey builds the key identifying a single entity in EntityHashes
+func EntityKey(refType string, id string) string {
+	return refType + ":" + id
+}
+
+// referenceHashes caches the hashes of a Reference
+type referenceHashes struct {
+	reference string
+	entities  map[string]string
+}
+
+// Hash returns a stable content hash of the whole Reference
+func (r *Reference) Hash() (string, error) {
+	return hashJSON(r)
+}
+
+// cachedHashes returns the hash of the Reference and of its entities,
+// computing them only the first time
+func (r *Reference) cachedHashes() (*referenceHashes, error) {
+	if r.hashes != nil {
+		return r.hashes, nil
+	}
+
+	referenceHash, err := r.Hash()
+	if err != nil {
+		return nil, err
+	}
+	entityHashes, err := r.EntityHashes()
+	if err != nil {
+		return nil, err
+	}
+
+	r.hashes = &referenceHashes{reference: referenceHash, entities: entityHashes}
+	return r.hashes, nil
+}
+
+// EntityHashes returns the content hash of every entity in the Reference,
+// keyed by EntityKey
+func (r *Reference) EntityHashes() (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	if err := addHashes(hashes, EntityKeyAncestry, r.Ancestries); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, EntityKeyCareer, r.Careers); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, EntityKeyClass, r.Classes); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, EntityKeyComplication, r.Complications); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, EntityKeyCulture, r.Cultures); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeAbility, r.Abilities); err != nil {
+		return nil, err
+	}
+	// each ability modifier is keyed by its ability and modifier IDs, as
+	// they're referred to on a sheet
+	modifiers := make(map[string]AbilityModifier)
+	for abilityID, ability := range r.Abilities {
+		for modifierID, modifier := range ability.Modifiers {
+			modifiers[abilityID+"."+modifierID] = modifier
+		}
+	}
+	if err := addHashes(hashes, RefIDTypeAbilityModifier, modifiers); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeDomain, r.Domains); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeFeature, r.Features); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeHeroicResource, r.HeroicResources); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeKit, r.Kits); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeLanguage, r.Languages); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeResource, r.Resources); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeSkill, r.Skills); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeSkillGroup, r.SkillGroups); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeTitle, r.Titles); err != nil {
+		return nil, err
+	}
+	if err := addHashes(hashes, RefIDTypeTreasure, r.Treasures); err != nil {
+		return nil, err
+	}
+
+	// each XP threshold is keyed by its level
+	thresholds := make(map[string]int)
+	for level, xp := range r.Leveling.XPThresholds {
+		thresholds[strconv.Itoa(level)] = xp
+	}
+	if err := addHashes(hashes, EntityKeyXPThreshold, thresholds); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// SheetEntityKeys returns the keys of every entity the sheet refers to
+func SheetEntityKeys(sheet *model.Sheet) []string {
+	keys := []string{EntityKey(EntityKeyClass, sheet.ClassID)}
+	if sheet.AncestryID != "" {
+		keys = append(keys, EntityKey(EntityKeyAncestry, sheet.AncestryID))
+	}
+	if sheet.CultureID != "" {
+		keys = append(keys, EntityKey(EntityKeyCulture, sheet.CultureID))
+	}
+	if sheet.CareerID != "" {
+		keys = append(keys, EntityKey(EntityKeyCareer, sheet.CareerID))
+	}
+	if sheet.HeroicResource != "" {
+		keys = append(keys, EntityKey(RefIDTypeHeroicResource, sheet.HeroicResource))
+	}
+	if sheet.ComplicationID != "" {
+		keys = append(keys, EntityKey(EntityKeyComplication, sheet.ComplicationID))
+	}
+
+	add := func(refType string, ids []string) {
+		for _, id := range ids {
+			keys = append(keys, EntityKey(refType, id))
+		}
+	}
+	add(RefIDTypeAbility, sheet.Abilities)
+	add(RefIDTypeAbilityModifier, sheet.AbilityModifiers)
+	add(RefIDTypeDomain, sheet.Domains)
+	add(RefIDTypeFeature, sheet.Features)
+	for featureID := range sheet.SupersededFeatures {
+		keys = append(keys, EntityKey(RefIDTypeFeature, featureID))
+	}
+	add(RefIDTypeKit, sheet.Kits)
+	add(RefIDTypeLanguage, sheet.Languages)
+	add(RefIDTypeResource, sheet.Resources)
+	add(RefIDTypeSkill, sheet.Skills)
+	add(RefIDTypeTitle, sheet.Titles)
+	for _, item := range sheet.Inventory {
+		keys = append(keys, EntityKey(RefIDTypeTreasure, item.TreasureID))
+	}
+
+	// a sheet leveled by XP depends on the thresholds from the next level up
+	// to the one after the highest level earned
+	if sheet.Leveling == model.LevelingXP {
+		earned := sheet.Level
+		if sheet.LevelUp != nil {
+			earned = sheet.LevelUp.Level
+		}
+		for level := sheet.Level + 1; level <= min(earned+1, MaxLevel); level++ {
+			keys = append(keys, EntityKey(EntityKeyXPThreshold, strconv.Itoa(level)))
+		}
+	}
+
+	sort.Strings(keys)
+	return slices.Compact(keys)
+}
+
+// StampSheet records the hash of the reference and of each entity the sheet
+// refers to
+func (r *Reference) StampSheet(sheet *model.Sheet) error {
+	hashes, err := r.cachedHashes()
+	if err != nil {
+		return err
+	}
+
+	sheet.ReferenceHash = hashes.reference
+	sheet.EntityHashes = make(map[string]string)
+	for _, key := range SheetEntityKeys(sheet) {
+		sheet.EntityHashes[key] = hashes.entities[key]
+	}
+
+	return nil
+}
+
+// A Staleness reports how a stored sheet differs from the current Reference
+type Staleness struct {
+	// Stale is true when any entity the sheet refers to has changed or been
+	// removed, or when the sheet was never stamped
+	Stale bool `json:"stale"`
+	// ReferenceChanged is true when anything in the Reference has changed,
+	// even if the sheet is unaffected
+	ReferenceChanged bool     `json:"reference_changed"`
+	Changed          []string `json:"changed"`
+	Removed          []string `json:"removed"`
+}
+
+// CheckStale compares the hashes stamped on a sheet against the Reference
+func (r *Reference) CheckStale(sheet *model.Sheet) (Staleness, error) {
+	hashes, err := r.cachedHashes()
+	if err != nil {
+		return Staleness{}, err
+	}
+	entityHashes := hashes.entities
+
+	staleness := Staleness{ReferenceChanged: sheet.ReferenceHash != hashes.reference}
+
+	// a sheet without hashes can't be checked, so assume the worst
+	if sheet.ReferenceHash == "" {
+		staleness.Stale = true
+		return staleness, nil
+	}
+
+	keys := make([]string, 0, len(sheet.EntityHashes))
+	for key := range sheet.EntityHashes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		current, ok := entityHashes[key]
+		if !ok {
+			staleness.Removed = append(staleness.Removed, key)
+			continue
+		}
+		if current != sheet.EntityHashes[key] {
+			staleness.Changed = append(staleness.Changed, key)
+		}
+	}
+
+	staleness.Stale = len(staleness.Changed) > 0 || len(staleness.Removed) > 0
+	return staleness, nil
+}
+
+func addHashes[T any](hashes map[string]string, refType string, entities map[string]T) error {
+	for id, entity := range entities {
+		hash, err := hashJSON(entity)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s \"%s\": %w", refType, id, err)
+		}
+		hashes[EntityKey(refType, id)] = hash
+	}
+	return nil
+}
+
+// hashJSON hashes the JSON encoding of value, which is stable since maps are
+// encoded with sorted keys
+func hashJSON(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,45 @@
+package rules
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/JamisonHubbard/dsbeyond/model"
+)
+
+// a sheet is stamped with the hash of each ability modifier it has, so a
+// change to the modifier makes the sheet stale
+func TestStampAbilityModifier(t *testing.T) {
+	reference := &Reference{
+		Abilities: map[string]Ability{
+			"smite": {ID: "smite", Name: "Smite", Modifiers: map[string]AbilityModifier{
+				"holy": {ID: "holy", Type: "upgrade"},
+			}},
+		},
+	}
+	sheet := model.Sheet{ClassID: testClassID, Abilities: []string{"smite"}, AbilityModifiers: []string{"smite.holy"}}
+
+	if err := reference.StampSheet(&sheet); err != nil {
+		t.Fatalf("failed to stamp: %s", err)
+	}
+	key := EntityKey(RefIDTypeAbilityModifier, "smite.holy")
+	if sheet.EntityHashes[key] == "" {
+		t.Fatalf("sheet has no hash for %s: %v", key, sheet.EntityHashes)
+	}
+
+	// a fresh Reference is needed, since hashes are kept once computed
+	changed := &Reference{
+		Abilities: map[string]Ability{
+			"smite": {ID: "smite", Name: "Smite", Modifiers: map[string]AbilityModifier{
+				"holy": {ID: "holy", Type: "replacement"},
+			}},
+		},
+	}
+	staleness, err := changed.CheckStale(&sheet)
+	if err != nil {
+		t.Fatalf("failed to check: %s", err)
+	}
+	if !staleness.Stale || !slices.Contains(staleness.Changed, key) {
+		t.Errorf("staleness is %+v, want %s changed", staleness, key)
+	}
+}
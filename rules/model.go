@@ -11,24 +11,46 @@ const (
 	RefIDTypeAbilityModifier = "ability_modifier"
 	RefIDTypeDomain          = "domain"
 	RefIDTypeFeature         = "feature"
+	RefIDTypeHeroicResource  = "heroic_resource"
 	RefIDTypeKit             = "kit"
+	RefIDTypeLanguage        = "language"
+	RefIDTypeResource        = "resource"
 	RefIDTypeSkill           = "skill"
+	RefIDTypeSkillGroup      = "skill_group"
+	RefIDTypeTitle           = "title"
+	RefIDTypeTreasure        = "treasure"
 )
 
 // A Reference contains all the static rules data for the game
 type Reference struct {
-	Abilities map[string]Ability
-	Classes   map[string]Class
-	Domains   map[string]Domain
-	Features  map[string]Feature
-	Kits      map[string]Kit
-	Skills    map[string]Skill
+	Abilities       map[string]Ability        `json:"abilities"`
+	Ancestries      map[string]Ancestry       `json:"ancestries"`
+	Careers         map[string]Career         `json:"careers"`
+	Classes         map[string]Class          `json:"classes"`
+	Complications   map[string]Complication   `json:"complications"`
+	Cultures        map[string]Culture        `json:"cultures"`
+	Domains         map[string]Domain         `json:"domains"`
+	Features        map[string]Feature        `json:"features"`
+	HeroicResources map[string]HeroicResource `json:"heroic_resources"`
+	Kits            map[string]Kit            `json:"kits"`
+	Languages       map[string]Language       `json:"languages"`
+	Resources       map[string]Resource       `json:"resources"`
+	Skills          map[string]Skill          `json:"skills"`
+	SkillGroups     map[string]SkillGroup     `json:"skill_groups"`
+	Titles          map[string]Title          `json:"titles"`
+	Treasures       map[string]Treasure       `json:"treasures"`
+	Leveling        Leveling                  `json:"leveling"`
+
+	// hashes are computed the first time they're needed, so the Reference
+	// must not change after it has stamped or checked a sheet
+	hashes *referenceHashes
 }
 
 const (
 	ValueRefTypeExpression = "expression"
 	ValueRefTypeID         = "id"
 	ValueRefTypeInt        = "int"
+	ValueRefTypeLevelTable = "level_table"
 	ValueRefTypeRefID      = "refid"
 	ValueRefTypeString     = "string"
 )
@@ -38,20 +60,37 @@ const (
 type ValueRef struct {
 	Type      string `json:"type"`
 	Value     any    `json:"value"`
-	RefIDType string `json:"refid_type"`
+	RefIDType string `json:"ref_type"`
 }
 
 const (
 	OperationTypeSet           = "set"
+	OperationTypeAdd           = "add"
+	OperationTypeSubtract      = "subtract"
 	OperationTypeAddAbility    = "add_ability"
+	OperationTypeAddBane       = "add_bane"
 	OperationTypeAddDomain     = "add_domain"
+	OperationTypeAddEdge       = "add_edge"
 	OperationTypeAddFeature    = "add_feature"
 	OperationTypeAddKit        = "add_kit"
+	OperationTypeAddLanguage   = "add_language"
 	OperationTypeAddSkill      = "add_skill"
+	OperationTypeAddSkillGroup = "add_skill_group"
+	OperationTypeAddTitle      = "add_title"
+	OperationTypeGrantImmunity = "grant_immunity"
+	OperationTypeGrantMovement = "grant_movement"
+	OperationTypeGrantResource = "grant_resource"
+	OperationTypeGrantWeakness = "grant_weakness"
 	OperationTypeModifyAbility = "modify_ability"
 )
 
 // An Operation is an action taken to set or modify a value in the context
+// NOTE: "add" and "subtract" operations modify a target that must already
+// have an int value, so they should come after whatever sets the target
+// NOTE: "grant_immunity" and "grant_weakness" target "immunities.<type>" or
+// "weaknesses.<type>" for a damage type, keeping the highest value granted
+// NOTE: "grant_movement" targets "movement.modes.<mode>" for a movement mode,
+// also keeping the highest speed granted
 type Operation struct {
 	Type     string      `json:"type"`
 	Target   string      `json:"target"`
@@ -80,6 +119,8 @@ type Assertion struct {
 const (
 	ExprTypeAdd      = "add"
 	ExprTypeSubtract = "subtract"
+	ExprTypeDivide   = "divide"
+	ExprTypeMax      = "max"
 )
 
 // An Expression is a mathematical statement that is evaluated at runtime to
@@ -90,9 +131,31 @@ type Expression struct {
 }
 
 const (
-	ChoiceTypeOptionSelect = "option_select"
-	ChoiceTypeRefSelect    = "ref_select"
-	ChoiceTypeInput        = "input"
+	LevelTableScaleLevel   = "level"
+	LevelTableScaleEchelon = "echelon"
+)
+
+// A LevelTable picks a value based on the character's level, or echelon if
+// the scale is "echelon"
+// NOTE: the entry with the highest threshold the character has reached is
+// used, so an entry with threshold 1 gives the value before any others apply
+type LevelTable struct {
+	Scale   string            `json:"scale"`
+	Entries []LevelTableEntry `json:"entries"`
+}
+
+// A LevelTableEntry is the value of a LevelTable from its threshold onwards
+type LevelTableEntry struct {
+	Threshold int      `json:"threshold"`
+	Value     ValueRef `json:"value"`
+}
+
+const (
+	ChoiceTypeOptionSelect        = "option_select"
+	ChoiceTypeRefSelect           = "ref_select"
+	ChoiceTypeInput               = "input"
+	ChoiceTypePointBuy            = "point_buy"
+	ChoiceTypeCharacteristicArray = "characteristic_array"
 )
 
 // A Choice represents a decision point during character creation that impacts
@@ -104,21 +167,44 @@ type Choice struct {
 	Prereqs []Assertion `json:"prereqs"`
 	Options []Option    `json:"options"`
 	RefType string      `json:"ref_type"`
+	// RefGroups limits a skill ref select to skills from these groups
+	RefGroups []string `json:"ref_groups"`
+	// RefIDs limits a ref select to these IDs
+	RefIDs []string `json:"ref_ids"`
+	// Points is the budget available to spend on options in a point buy
+	Points int `json:"points"`
+	// OperationType is the operation an input is applied with, defaulting to
+	// set
+	OperationType string `json:"operation_type"`
+	// RecordTarget is a value that the ID picked in a ref select is also set
+	// on, e.g. to record it in the class sheet
+	RecordTarget string `json:"record_target"`
+	// Arrays are the sets of scores a characteristic array can assign to its
+	// Characteristics, defaulting to every characteristic
+	Arrays          [][]int  `json:"arrays"`
+	Characteristics []string `json:"characteristics"`
 }
 
-// An Option is a possible decision made to resolve a Choice
+// An Option is a possible decision made to resolve a Choice. Selecting it
+// applies its Operations and makes its nested Choices available.
 type Option struct {
 	ID         string      `json:"id"`
+	Cost       int         `json:"cost"`
 	Operations []Operation `json:"operations"`
+	Choices    []Choice    `json:"choices"`
 }
 
 // A Decision represents the result of a Choice that was made during character
 // creation
 type Decision struct {
-	ChoiceID string   `json:"choice_id"`
-	OptionID string   `json:"option_id"`
-	RefID    string   `json:"ref_id"`
-	Value    ValueRef `json:"value"`
+	ChoiceID  string   `json:"choice_id"`
+	OptionID  string   `json:"option_id"`
+	OptionIDs []string `json:"option_ids"`
+	RefID     string   `json:"ref_id"`
+	Value     ValueRef `json:"value"`
+	// Assignments maps each characteristic to its score from a
+	// characteristic array
+	Assignments map[string]int `json:"assignments"`
 }
 
 // UnmarshalJSON is a custom unmarshaller for ValueRef
@@ -130,6 +216,11 @@ func (v *ValueRef) UnmarshalJSON(data []byte) error {
 		RefType string          `json:"ref_type"`
 	}
 
+	// a null ValueRef is the zero value, matching what MarshalJSON produces
+	if string(data) == "null" {
+		return nil
+	}
+
 	var tmp rawValueRef
 	if err := json.Unmarshal(data, &tmp); err != nil {
 		return err
@@ -169,8 +260,73 @@ func (v *ValueRef) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		v.Value = &expr
+	case ValueRefTypeLevelTable:
+		var table LevelTable
+		if err := json.Unmarshal(tmp.Value, &table); err != nil {
+			return err
+		}
+		v.Value = &table
 	default:
 		return fmt.Errorf("invalid ValueRef type: %s", v.Type)
 	}
 	return nil
 }
+
+// MarshalJSON is a custom marshaller for ValueRef that produces the same shape
+// read by UnmarshalJSON
+func (v ValueRef) MarshalJSON() ([]byte, error) {
+	// an unset ValueRef is written as null so it decodes back to the zero value
+	if v.Type == "" && v.Value == nil && v.RefIDType == "" {
+		return []byte("null"), nil
+	}
+
+	type rawValueRef struct {
+		Type    string `json:"type"`
+		Value   any    `json:"value"`
+		RefType string `json:"ref_type,omitempty"`
+	}
+
+	tmp := rawValueRef{
+		Type:    v.Type,
+		Value:   v.Value,
+		RefType: v.RefIDType,
+	}
+
+	// make sure the value has the type the unmarshaller will produce
+	switch v.Type {
+	case ValueRefTypeInt:
+		if _, ok := v.Value.(int); !ok {
+			return nil, fmt.Errorf("ValueRef of type %s has %T value", v.Type, v.Value)
+		}
+	case ValueRefTypeString, ValueRefTypeID, ValueRefTypeRefID:
+		if _, ok := v.Value.(string); !ok {
+			return nil, fmt.Errorf("ValueRef of type %s has %T value", v.Type, v.Value)
+		}
+	case ValueRefTypeExpression:
+		switch expr := v.Value.(type) {
+		case *Expression:
+			if expr == nil {
+				return nil, fmt.Errorf("ValueRef of type %s has nil value", v.Type)
+			}
+		case Expression:
+			tmp.Value = &expr
+		default:
+			return nil, fmt.Errorf("ValueRef of type %s has %T value", v.Type, v.Value)
+		}
+	case ValueRefTypeLevelTable:
+		switch table := v.Value.(type) {
+		case *LevelTable:
+			if table == nil {
+				return nil, fmt.Errorf("ValueRef of type %s has nil value", v.Type)
+			}
+		case LevelTable:
+			tmp.Value = &table
+		default:
+			return nil, fmt.Errorf("ValueRef of type %s has %T value", v.Type, v.Value)
+		}
+	default:
+		return nil, fmt.Errorf("invalid ValueRef type: %s", v.Type)
+	}
+
+	return json.Marshal(tmp)
+}
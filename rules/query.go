@@ -0,0 +1,326 @@
+package rules
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// An AbilityFilter selects abilities from the Reference. Each non-empty field
+// narrows the results, and a slice field matches if any of its entries match.
+type AbilityFilter struct {
+	// Keywords must all be present on the ability
+	Keywords            []string
+	ActionTypes         []string
+	Types               []string
+	HeroicResourceCosts []int
+	RangeTypes          []string
+	RangeSubtypes       []string
+	// ClassIDs matches abilities granted by any level of the class, directly
+	// or through an option or a feature
+	ClassIDs []string
+}
+
+// FilterAbilities returns the abilities matching the filter, sorted by ID
+func (r *Reference) FilterAbilities(filter AbilityFilter) []Ability {
+	var classAbilities map[string]bool
+	if len(filter.ClassIDs) > 0 {
+		classAbilities = make(map[string]bool)
+		for _, classID := range filter.ClassIDs {
+			for abilityID := range r.ClassAbilityIDs(classID) {
+				classAbilities[abilityID] = true
+			}
+		}
+	}
+
+	var abilities []Ability
+	for _, ability := range r.Abilities {
+		if !containsAll(ability.Keywords, filter.Keywords) {
+			continue
+		}
+		if !matchesAny(filter.ActionTypes, ability.ActionType) {
+			continue
+		}
+		if !matchesAny(filter.Types, ability.Type) {
+			continue
+		}
+		if !matchesAny(filter.HeroicResourceCosts, ability.HeroicResourceCost) {
+			continue
+		}
+		if !matchesAny(filter.RangeTypes, ability.Range.Type) {
+			continue
+		}
+		if !matchesAny(filter.RangeSubtypes, ability.Range.Subtype) {
+			continue
+		}
+		if classAbilities != nil && !classAbilities[ability.ID] {
+			continue
+		}
+		abilities = append(abilities, ability)
+	}
+
+	sort.Slice(abilities, func(i, j int) bool { return abilities[i].ID < abilities[j].ID })
+	return abilities
+}
+
+// ClassAbilityIDs returns the IDs of every ability the class can grant at any
+// level, including those offered by options and choices, and granted by
+// features and whatever those features grant in turn. An ability ref select
+// offers only its RefIDs, as one left open isn't tied to the class.
+func (r *Reference) ClassAbilityIDs(classID string) map[string]bool {
+	abilityIDs := make(map[string]bool)
+
+	class, ok := r.Classes[classID]
+	if !ok {
+		return abilityIDs
+	}
+
+	var operations []Operation
+	for _, level := range class.Levels {
+		operations = append(operations, r.grantOperations(level.Operations, level.Choices, abilityIDs)...)
+	}
+
+	// features are expanded after the class levels so features granted by
+	// features are found too. An unknown feature grants nothing here, and is
+	// reported when a character with it is resolved.
+	_ = r.walkFeatures(operations, func(feature *Feature) []Operation {
+		for _, abilityID := range feature.Abilities {
+			abilityIDs[abilityID] = true
+		}
+		return r.grantOperations(feature.Operations, feature.Choices, abilityIDs)
+	})
+
+	return abilityIDs
+}
+
+// grantOperations returns the operations and the operations of every option
+// of the choices, including those of nested choices, recording the abilities
+// they add in abilityIDs
+func (r *Reference) grantOperations(operations []Operation, choices []Choice, abilityIDs map[string]bool) []Operation {
+	result := slices.Clone(operations)
+	for _, choice := range choices {
+		if choice.Type == ChoiceTypeRefSelect && choice.RefType == RefIDTypeAbility {
+			for _, abilityID := range choice.RefIDs {
+				abilityIDs[abilityID] = true
+			}
+		}
+		for _, option := range choice.Options {
+			result = append(result, r.grantOperations(option.Operations, option.Choices, abilityIDs)...)
+		}
+	}
+
+	for _, operation := range result {
+		if operation.Type != OperationTypeAddAbility || operation.ValueRef.Type != ValueRefTypeRefID {
+			continue
+		}
+		if abilityID, ok := operation.ValueRef.Value.(string); ok {
+			abilityIDs[abilityID] = true
+		}
+	}
+
+	return result
+}
+
+// A FeatureFilter selects features from the Reference
+type FeatureFilter struct {
+	Types []string
+}
+
+// FilterFeatures returns the features matching the filter, sorted by ID
+func (r *Reference) FilterFeatures(filter FeatureFilter) []Feature {
+	var features []Feature
+	for _, feature := range r.Features {
+		if !matchesAny(filter.Types, feature.Type) {
+			continue
+		}
+		features = append(features, feature)
+	}
+
+	sort.Slice(features, func(i, j int) bool { return features[i].ID < features[j].ID })
+	return features
+}
+
+// A KitFilter selects kits from the Reference
+type KitFilter struct {
+	ArmorTypes []string
+	// WeaponTypes matches kits that use any of the weapon types
+	WeaponTypes []string
+}
+
+// FilterKits returns the kits matching the filter, sorted by ID
+func (r *Reference) FilterKits(filter KitFilter) []Kit {
+	var kits []Kit
+	for _, kit := range r.Kits {
+		if !matchesAny(filter.ArmorTypes, kit.Equipment.ArmorType) {
+			continue
+		}
+		if len(filter.WeaponTypes) > 0 && !slices.ContainsFunc(kit.Equipment.Weapons, func(weapon KitWeapon) bool {
+			return slices.Contains(filter.WeaponTypes, weapon.Type)
+		}) {
+			continue
+		}
+		kits = append(kits, kit)
+	}
+
+	sort.Slice(kits, func(i, j int) bool { return kits[i].ID < kits[j].ID })
+	return kits
+}
+
+// matchesAny reports whether value is one of allowed, with an empty allowed
+// list matching everything
+func matchesAny[T comparable](allowed []T, value T) bool {
+	return len(allowed) == 0 || slices.Contains(allowed, value)
+}
+
+func containsAll(values []string, required []string) bool {
+	for _, value := range required {
+		if !slices.Contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// weights given to a search term depending on where it was found
+const (
+	searchWeightName        = 10
+	searchWeightKeyword     = 5
+	searchWeightDescription = 3
+	searchWeightSection     = 1
+)
+
+// A SearchResult is a single entity matched by Search
+type SearchResult struct {
+	RefType string `json:"ref_type"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Score   int    `json:"score"`
+}
+
+// Search performs a full-text search over the names, descriptions and section
+// text of abilities, features, kits, skills and domains. Every term in the
+// query must be found for an entity to match, and results are ranked by
+// score, then by ID.
+func (r *Reference) Search(query string) []SearchResult {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var results []SearchResult
+	add := func(refType string, id string, name string, fields []searchField) {
+		score := scoreTerms(terms, fields)
+		if score > 0 {
+			results = append(results, SearchResult{RefType: refType, ID: id, Name: name, Score: score})
+		}
+	}
+
+	for _, ability := range r.Abilities {
+		fields := []searchField{
+			{ability.Name, searchWeightName},
+			{strings.Join(ability.Keywords, " "), searchWeightKeyword},
+			{ability.Description, searchWeightDescription},
+		}
+		for _, section := range ability.Sections {
+			fields = append(fields, abilitySectionFields(section)...)
+		}
+		for _, modifier := range ability.Modifiers {
+			for _, section := range modifier.Sections {
+				fields = append(fields, abilitySectionFields(section)...)
+			}
+		}
+		add(RefIDTypeAbility, ability.ID, ability.Name, fields)
+	}
+
+	for _, feature := range r.Features {
+		fields := []searchField{{feature.Name, searchWeightName}}
+		for _, section := range feature.Sections {
+			fields = append(fields, searchField{section.Text, searchWeightSection})
+		}
+		add(RefIDTypeFeature, feature.ID, feature.Name, fields)
+	}
+
+	for _, kit := range r.Kits {
+		add(RefIDTypeKit, kit.ID, kit.Name, []searchField{
+			{kit.Name, searchWeightName},
+			{kit.Description, searchWeightDescription},
+		})
+	}
+
+	for _, skill := range r.Skills {
+		add(RefIDTypeSkill, skill.ID, skill.Name, []searchField{
+			{skill.Name, searchWeightName},
+			{skill.Description, searchWeightDescription},
+		})
+	}
+
+	for _, domain := range r.Domains {
+		add(RefIDTypeDomain, domain.ID, domain.Name, []searchField{
+			{domain.Name, searchWeightName},
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].RefType != results[j].RefType {
+			return results[i].RefType < results[j].RefType
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
+
+type searchField struct {
+	text   string
+	weight int
+}
+
+func abilitySectionFields(section AbilitySection) []searchField {
+	fields := []searchField{
+		{section.Title, searchWeightSection},
+		{section.Text, searchWeightSection},
+	}
+	for _, result := range []AbilityRollResult{section.Roll.Results.TierI, section.Roll.Results.TierII, section.Roll.Results.TierIII} {
+		fields = append(fields,
+			searchField{result.Effect, searchWeightSection},
+			searchField{result.PotencyEffect.Effect, searchWeightSection},
+		)
+	}
+	return fields
+}
+
+// scoreTerms sums the weighted occurrences of each term across the fields,
+// returning zero if any term is missing
+func scoreTerms(terms []string, fields []searchField) int {
+	tokenized := make([][]string, len(fields))
+	for i, field := range fields {
+		tokenized[i] = tokenize(field.text)
+	}
+
+	var total int
+	for _, term := range terms {
+		var score int
+		for i, field := range fields {
+			for _, token := range tokenized[i] {
+				if strings.HasPrefix(token, term) {
+					score += field.weight
+				}
+			}
+		}
+		if score == 0 {
+			return 0
+		}
+		total += score
+	}
+	return total
+}
+
+// tokenize splits text into lowercase words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,187 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// queryReference returns a small Reference covering each field the filters
+// and search look at
+func queryReference() *Reference {
+	refID := func(id string, refType string) ValueRef {
+		return ValueRef{Type: ValueRefTypeRefID, Value: id, RefIDType: refType}
+	}
+
+	return &Reference{
+		Abilities: map[string]Ability{
+			"strike": {ID: "strike", Name: "Mighty Strike", Type: AbilityTypeSignature, ActionType: ActionTypeMain,
+				Keywords: []string{"melee", "strike", "weapon"}, Range: Range{Type: RangeTypeDistance, Subtype: DistanceTypeMelee}},
+			"volley": {ID: "volley", Name: "Volley", Type: AbilityTypeSignature, ActionType: ActionTypeMain,
+				Keywords: []string{"ranged", "weapon"}, Range: Range{Type: RangeTypeDistance, Subtype: DistanceTypeRanged}},
+			"smite": {ID: "smite", Name: "Smite", Type: AbilityTypeHeroic, ActionType: ActionTypeMain, HeroicResourceCost: 3,
+				Keywords: []string{"magic", "melee"}, Range: Range{Type: RangeTypeDistance, Subtype: DistanceTypeMelee}},
+			"shield": {ID: "shield", Name: "Shield of Faith", Type: AbilityTypeHeroic, ActionType: ActionTypeManeuver, HeroicResourceCost: 5,
+				Keywords: []string{"magic"}, Range: Range{Type: RangeTypeArea, Subtype: AreaTypeAura}},
+			"ward": {ID: "ward", Name: "Ward", Type: AbilityTypeSignature, ActionType: ActionTypeTriggered,
+				Keywords: []string{"magic"}, Range: Range{Type: RangeTypeDistance, Subtype: DistanceTypeSelf}},
+			"blast": {ID: "blast", Name: "Fire Blast", Type: AbilityTypeHeroic, ActionType: ActionTypeMain, HeroicResourceCost: 3,
+				Keywords: []string{"magic", "ranged"}, Range: Range{Type: RangeTypeArea, Subtype: AreaTypeBurst}},
+		},
+		Classes: map[string]Class{
+			"tester": {ID: "tester", Name: "Tester", Levels: map[int]ClassLevel{
+				1: {
+					Operations: []Operation{
+						{Type: OperationTypeAddAbility, Target: AbilitiesValueName, ValueRef: refID("strike", RefIDTypeAbility)},
+						{Type: OperationTypeAddFeature, Target: FeaturesValueName, ValueRef: refID("aura", RefIDTypeFeature)},
+					},
+					Choices: []Choice{
+						{ID: "heroic", Type: ChoiceTypeOptionSelect, Options: []Option{
+							{ID: "smite", Operations: []Operation{
+								{Type: OperationTypeAddAbility, Target: AbilitiesValueName, ValueRef: refID("smite", RefIDTypeAbility)},
+							}},
+						}},
+						{ID: "triggered", Type: ChoiceTypeRefSelect, RefType: RefIDTypeAbility, RefIDs: []string{"ward"}},
+						{ID: "any", Type: ChoiceTypeRefSelect, RefType: RefIDTypeAbility},
+					},
+				},
+			}},
+		},
+		Features: map[string]Feature{
+			"aura": {ID: "aura", Name: "Burning Aura", Abilities: []string{"shield"},
+				Sections: []FeatureSection{{Type: FeatureSectionTypeText, Text: "Fire surrounds you."}}},
+			"keen": {ID: "keen", Name: "Keen", Type: FeatureTypePerk},
+			"hide": {ID: "hide", Name: "Thick Hide", Type: FeatureTypeAncestryTrait},
+		},
+		Kits: map[string]Kit{
+			"archer": {ID: "archer", Name: "Archer", Description: "Fire arrows from afar.",
+				Equipment: KitEquipment{ArmorType: ArmorTypeLight, Weapons: []KitWeapon{{Type: WeaponTypeBow}}}},
+			"guard": {ID: "guard", Name: "Guard",
+				Equipment: KitEquipment{ArmorType: ArmorTypeHeavy, Shield: true, Weapons: []KitWeapon{{Type: WeaponTypeMedium}, {Type: WeaponTypePolearm}}}},
+			"brawler": {ID: "brawler", Name: "Brawler",
+				Equipment: KitEquipment{Weapons: []KitWeapon{{Type: WeaponTypeUnarmed}}}},
+		},
+		Skills: map[string]Skill{
+			"sun_lore": {ID: "sun_lore", Name: "Sun Lore", Group: "lore"},
+		},
+		Domains: map[string]Domain{
+			"sun": {ID: "sun", Name: "Sun"},
+		},
+	}
+}
+
+func TestFilterAbilities(t *testing.T) {
+	reference := queryReference()
+
+	tests := []struct {
+		name   string
+		filter AbilityFilter
+		want   []string
+	}{
+		{name: "no filter", filter: AbilityFilter{}, want: []string{"blast", "shield", "smite", "strike", "volley", "ward"}},
+		{name: "all keywords required", filter: AbilityFilter{Keywords: []string{"magic", "melee"}}, want: []string{"smite"}},
+		{name: "any action type", filter: AbilityFilter{ActionTypes: []string{ActionTypeManeuver, ActionTypeTriggered}}, want: []string{"shield", "ward"}},
+		{name: "ability type", filter: AbilityFilter{Types: []string{AbilityTypeHeroic}}, want: []string{"blast", "shield", "smite"}},
+		{name: "heroic resource cost", filter: AbilityFilter{HeroicResourceCosts: []int{3}}, want: []string{"blast", "smite"}},
+		{name: "range type", filter: AbilityFilter{RangeTypes: []string{RangeTypeArea}}, want: []string{"blast", "shield"}},
+		{name: "range subtype", filter: AbilityFilter{RangeSubtypes: []string{DistanceTypeMelee}}, want: []string{"smite", "strike"}},
+		{name: "class grants", filter: AbilityFilter{ClassIDs: []string{"tester"}}, want: []string{"shield", "smite", "strike", "ward"}},
+		{name: "unknown class", filter: AbilityFilter{ClassIDs: []string{"nobody"}}, want: nil},
+		{name: "fields combined", filter: AbilityFilter{ClassIDs: []string{"tester"}, Keywords: []string{"magic"}}, want: []string{"shield", "smite", "ward"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, ability := range reference.FilterAbilities(test.filter) {
+				got = append(got, ability.ID)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterFeatures(t *testing.T) {
+	reference := queryReference()
+
+	tests := []struct {
+		name   string
+		filter FeatureFilter
+		want   []string
+	}{
+		{name: "no filter", filter: FeatureFilter{}, want: []string{"aura", "hide", "keen"}},
+		{name: "one type", filter: FeatureFilter{Types: []string{FeatureTypePerk}}, want: []string{"keen"}},
+		{name: "any type", filter: FeatureFilter{Types: []string{FeatureTypePerk, FeatureTypeAncestryTrait}}, want: []string{"hide", "keen"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, feature := range reference.FilterFeatures(test.filter) {
+				got = append(got, feature.ID)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterKits(t *testing.T) {
+	reference := queryReference()
+
+	tests := []struct {
+		name   string
+		filter KitFilter
+		want   []string
+	}{
+		{name: "no filter", filter: KitFilter{}, want: []string{"archer", "brawler", "guard"}},
+		{name: "armor type", filter: KitFilter{ArmorTypes: []string{ArmorTypeHeavy}}, want: []string{"guard"}},
+		{name: "any weapon type", filter: KitFilter{WeaponTypes: []string{WeaponTypePolearm, WeaponTypeBow}}, want: []string{"archer", "guard"}},
+		{name: "fields combined", filter: KitFilter{ArmorTypes: []string{ArmorTypeLight}, WeaponTypes: []string{WeaponTypePolearm}}, want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, kit := range reference.FilterKits(test.filter) {
+				got = append(got, kit.ID)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	reference := queryReference()
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "empty query", query: " ", want: nil},
+		{name: "name and keyword", query: "strike", want: []string{"ability/strike/15"}},
+		{name: "prefix of a word", query: "STR", want: []string{"ability/strike/15"}},
+		{name: "ranked by where the term is found", query: "fire", want: []string{"ability/blast/10", "kit/archer/3", "feature/aura/1"}},
+		{name: "ties ranked by type then ID", query: "sun", want: []string{"domain/sun/10", "skill/sun_lore/10"}},
+		{name: "every term required", query: "fire strike", want: nil},
+		{name: "no match", query: "dragon", want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, result := range reference.Search(test.query) {
+				got = append(got, fmt.Sprintf("%s/%s/%d", result.RefType, result.ID, result.Score))
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
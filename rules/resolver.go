@@ -1,9 +1,11 @@
 package rules
 
 import (
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"slices"
 	"sort"
 	"strings"
@@ -30,12 +32,105 @@ func NewResolver(character model.Character, decisions map[string]Decision, refer
 const (
 	AbilitiesValueName        = "abilities"
 	AbilityModifiersValueName = "ability_modifiers"
+	BanesValueName            = "banes"
 	DomainsValueName          = "domains"
+	EdgesValueName            = "edges"
 	FeaturesValueName         = "features"
+	HeroicResourceValueName   = "heroic_resource"
+	ImmunitiesValueName       = "immunities"
 	KitsValueName             = "kits"
+	LanguagesValueName        = "languages"
+	ResourcesValueName        = "resources"
 	SkillsValueName           = "skills"
+	SupersededValueName       = "superseded_features"
+	TitlesValueName           = "titles"
+	WeaknessesValueName       = "weaknesses"
 )
 
+// LevelValueName holds the character's level, so operations can scale with it
+const LevelValueName = "level"
+
+// defaultValues start at zero so that operations can add to them without
+// anything else setting them first
+var defaultValues = []string{
+	"characteristics.might",
+	"characteristics.agility",
+	"characteristics.reason",
+	"characteristics.intuition",
+	"characteristics.presence",
+	"damage_bonuses.melee",
+	"damage_bonuses.ranged",
+}
+
+// SaveTargetValueName holds the lowest d10 roll that succeeds on a saving
+// throw, which starts at SaveTargetBase
+const SaveTargetValueName = "saves.target"
+
+const SaveTargetBase = 6
+
+// derivedValues are calculated from the rest of the sheet once every other
+// value is known, so they include bonuses like those from kits. Operations
+// targeting them are applied on top, e.g. to add to the recovery value.
+// NOTE: derived values are listed in the order they're evaluated
+var derivedValues = []Operation{
+	{Type: OperationTypeSet, Target: "health.recovery_value", ValueRef: ValueRef{Type: ValueRefTypeExpression, Value: &Expression{
+		Type: ExprTypeDivide,
+		Args: []ValueRef{
+			{Type: ValueRefTypeID, Value: "health.max_stamina"},
+			{Type: ValueRefTypeInt, Value: 3},
+		},
+	}}},
+	{Type: OperationTypeSet, Target: "health.winded_value", ValueRef: ValueRef{Type: ValueRefTypeExpression, Value: &Expression{
+		Type: ExprTypeDivide,
+		Args: []ValueRef{
+			{Type: ValueRefTypeID, Value: "health.max_stamina"},
+			{Type: ValueRefTypeInt, Value: 2},
+		},
+	}}},
+	{Type: OperationTypeSet, Target: "health.dying_value", ValueRef: ValueRef{Type: ValueRefTypeExpression, Value: &Expression{
+		Type: ExprTypeSubtract,
+		Args: []ValueRef{
+			{Type: ValueRefTypeInt, Value: 0},
+			{Type: ValueRefTypeID, Value: "health.winded_value"},
+		},
+	}}},
+	{Type: OperationTypeSet, Target: "surges.damage", ValueRef: ValueRef{Type: ValueRefTypeExpression, Value: &Expression{
+		Type: ExprTypeMax,
+		Args: []ValueRef{
+			{Type: ValueRefTypeID, Value: "characteristics.might"},
+			{Type: ValueRefTypeID, Value: "characteristics.agility"},
+			{Type: ValueRefTypeID, Value: "characteristics.reason"},
+			{Type: ValueRefTypeID, Value: "characteristics.intuition"},
+			{Type: ValueRefTypeID, Value: "characteristics.presence"},
+		},
+	}}},
+}
+
+// CharacteristicsValuePrefix starts every target that sets a characteristic
+const CharacteristicsValuePrefix = "characteristics."
+
+// SizeValueName holds the character's size, which must parse as a model.Size.
+// It starts at DefaultSize until the character's ancestry sets it.
+const SizeValueName = "movement.size"
+
+const DefaultSize = "1M"
+
+// SpeedValueName holds the character's speed, which starts at DefaultSpeed
+// until the character's ancestry sets it
+const SpeedValueName = "movement.speed"
+
+const DefaultSpeed = 5
+
+// MovementModesValueName holds the speed of each movement mode
+const MovementModesValueName = "movement.modes"
+
+// ClassValuePrefix starts every target that sets a class specific value
+const ClassValuePrefix = "class."
+
+// ComplicationChoiceID is the ID of the Decision that picks a character's
+// complication, if they have one
+const ComplicationChoiceID = "complication"
+
 type Resolver struct {
 	// inputs
 	character model.Character
@@ -43,6 +138,7 @@ type Resolver struct {
 	reference *Reference
 
 	// internals
+	class      *Class
 	values     map[string]any
 	operations map[string][]*Operation
 	visited    map[string]bool
@@ -58,14 +154,61 @@ func (r *Resolver) Resolve() (model.Sheet, error) {
 		return model.Sheet{}, fmt.Errorf("class \"%s\" not found", r.character.ClassID)
 	}
 
+	// get the ancestry, culture and career the character was built with, and
+	// the complication they took
+	grants, err := r.backgroundGrants()
+	if err != nil {
+		return model.Sheet{}, err
+	}
+	complication, err := r.complicationGrants()
+	if err != nil {
+		return model.Sheet{}, err
+	}
+	grants = append(grants, complication...)
+
+	// titles are earned during play, so they come after the character's
+	// background
+	titles, err := r.titleGrants()
+	if err != nil {
+		return model.Sheet{}, err
+	}
+	grants = append(grants, titles...)
+
+	// equipped treasures apply on top of everything else
+	treasures, err := r.treasureGrants()
+	if err != nil {
+		return model.Sheet{}, err
+	}
+	grants = append(grants, treasures...)
+
 	// setup values and operations
-	r.setup(&class)
+	r.class = &class
+	r.setup(&class, grants)
 	if r.error != nil {
 		return model.Sheet{}, r.error
 	}
 
-	// execute operations
-	for node := range r.operations {
+	// execute operations in a stable order, leaving derived values and
+	// movement modes until the rest are known
+	var modes []string
+	for _, node := range slices.Sorted(maps.Keys(r.operations)) {
+		if isDerivedValue(node) {
+			continue
+		}
+		// modes are often based on speed, which kits can still change
+		if strings.HasPrefix(node, MovementModesValueName+".") {
+			modes = append(modes, node)
+			continue
+		}
+
+		r.trace.Push("node:" + node)
+		r.EvaluateNode(node)
+		if r.error != nil {
+			return model.Sheet{}, r.error
+		}
+		r.trace.Pop()
+	}
+	for _, node := range modes {
 		r.trace.Push("node:" + node)
 		r.EvaluateNode(node)
 		if r.error != nil {
@@ -73,6 +216,21 @@ func (r *Resolver) Resolve() (model.Sheet, error) {
 		}
 		r.trace.Pop()
 	}
+	for _, derived := range derivedValues {
+		r.trace.Push("node:" + derived.Target)
+		r.EvaluateNode(derived.Target)
+		if r.error != nil {
+			return model.Sheet{}, r.error
+		}
+		r.trace.Pop()
+	}
+
+	// levels earned with XP but not taken yet, found while the values are
+	// still flat so the prereqs of the choices they unlock can be checked
+	levelUp, err := r.levelUp(&class)
+	if err != nil {
+		return model.Sheet{}, err
+	}
 
 	// process values to unflatten them
 	r.unflattenValues()
@@ -80,6 +238,9 @@ func (r *Resolver) Resolve() (model.Sheet, error) {
 		return model.Sheet{}, r.error
 	}
 
+	// the class ID decides which class sheet the class values decode into
+	r.values["class_id"] = r.character.ClassID
+
 	// create sheet
 	data, err := json.Marshal(r.values)
 	if err != nil {
@@ -90,10 +251,91 @@ func (r *Resolver) Resolve() (model.Sheet, error) {
 	if err != nil {
 		return model.Sheet{}, fmt.Errorf("failed to unmarshal sheet: %w", err)
 	}
+	sheet.CharacterID = r.character.ID
+	sheet.ClassID = r.character.ClassID
+	sheet.AncestryID = r.character.AncestryID
+	sheet.CultureID = r.character.CultureID
+	sheet.CareerID = r.character.CareerID
+	sheet.ComplicationID = r.decisions[ComplicationChoiceID].RefID
+	sheet.Level = r.character.Level
+	sheet.XP = r.character.XP
+	sheet.Victories = r.character.Victories
+	sheet.Leveling = cmp.Or(r.character.Leveling, model.LevelingManual)
+	sheet.LevelUp = levelUp
+	sheet.Inventory = r.character.Inventory
+
+	// record the reference data the sheet was resolved against
+	err = r.reference.StampSheet(&sheet)
+	if err != nil {
+		return model.Sheet{}, fmt.Errorf("failed to stamp sheet: %w", err)
+	}
 
 	return sheet, nil
 }
 
+// levelUp finds the levels a character leveled by XP has earned but not yet
+// taken, along with the class choices those levels unlock
+func (r *Resolver) levelUp(class *Class) (*model.LevelUp, error) {
+	switch r.character.Leveling {
+	case model.LevelingManual, "":
+		return nil, nil
+	case model.LevelingXP:
+	default:
+		return nil, fmt.Errorf("invalid leveling \"%s\"", r.character.Leveling)
+	}
+
+	earned, err := r.reference.Leveling.LevelForXP(r.character.XP)
+	if err != nil {
+		return nil, err
+	}
+	if r.character.Level > earned {
+		return nil, fmt.Errorf("level %d is higher than level %d earned with %d xp", r.character.Level, earned, r.character.XP)
+	}
+	if r.character.Level == earned {
+		return nil, nil
+	}
+
+	levelUp := &model.LevelUp{Level: earned}
+	for level := r.character.Level + 1; level <= earned; level++ {
+		r.levelUpChoices(class.Levels[level].Choices, levelUp)
+		if r.error != nil {
+			return nil, r.error
+		}
+	}
+
+	return levelUp, nil
+}
+
+// levelUpChoices adds the IDs of the choices that apply to the level up,
+// along with the nested choices of their options like walkChoices
+func (r *Resolver) levelUpChoices(choices []Choice, levelUp *model.LevelUp) {
+	for _, choice := range choices {
+		// choices for a different order or domain aren't unlocked, and
+		// neither are the choices nested in them
+		applies := true
+		for _, assertion := range choice.Prereqs {
+			if !r.checkAssertion(&assertion) {
+				applies = false
+				break
+			}
+		}
+		if r.error != nil {
+			return
+		}
+		if !applies {
+			continue
+		}
+
+		levelUp.Choices = append(levelUp.Choices, choice.ID)
+		for _, option := range choice.Options {
+			r.levelUpChoices(option.Choices, levelUp)
+			if r.error != nil {
+				return
+			}
+		}
+	}
+}
+
 func (r *Resolver) unflattenValues() {
 	unflattened := make(map[string]any)
 	for key, value := range r.values {
@@ -113,9 +355,137 @@ func (r *Resolver) unflattenValues() {
 	r.values = unflattened
 }
 
-// setup parses the class and decisions to generate the Operations that must be
-// evaluated to resolve the character sheet
-func (r *Resolver) setup(class *Class) {
+// A grant is a package of Operations and Choices that a character receives
+// outside of their class levels, such as from their ancestry
+type grant struct {
+	operations []Operation
+	choices    []Choice
+}
+
+// backgroundGrants looks up the ancestry, culture and career of the character,
+// skipping any that haven't been chosen
+func (r *Resolver) backgroundGrants() ([]grant, error) {
+	var grants []grant
+
+	if r.character.AncestryID != "" {
+		ancestry, ok := r.reference.Ancestries[r.character.AncestryID]
+		if !ok {
+			return nil, fmt.Errorf("ancestry \"%s\" not found", r.character.AncestryID)
+		}
+		grants = append(grants, grant{operations: ancestry.Operations, choices: ancestry.Choices})
+	}
+
+	if r.character.CultureID != "" {
+		culture, ok := r.reference.Cultures[r.character.CultureID]
+		if !ok {
+			return nil, fmt.Errorf("culture \"%s\" not found", r.character.CultureID)
+		}
+		grants = append(grants, grant{operations: culture.Operations, choices: culture.Choices})
+	}
+
+	if r.character.CareerID != "" {
+		career, ok := r.reference.Careers[r.character.CareerID]
+		if !ok {
+			return nil, fmt.Errorf("career \"%s\" not found", r.character.CareerID)
+		}
+		grants = append(grants, grant{operations: career.Operations, choices: career.Choices})
+	}
+
+	return grants, nil
+}
+
+// complicationGrants looks up the complication chosen for the character,
+// returning its benefit and drawback as grants
+func (r *Resolver) complicationGrants() ([]grant, error) {
+	decision, ok := r.decisions[ComplicationChoiceID]
+	if !ok {
+		return nil, nil
+	}
+
+	complication, ok := r.reference.Complications[decision.RefID]
+	if !ok {
+		return nil, fmt.Errorf("complication \"%s\" not found", decision.RefID)
+	}
+
+	return []grant{
+		{operations: complication.Benefit.Operations, choices: complication.Benefit.Choices},
+		{operations: complication.Drawback.Operations, choices: complication.Drawback.Choices},
+	}, nil
+}
+
+// titleGrants looks up every title the character has earned, returning a grant
+// that adds the title and its chosen benefit when its prereqs are met
+func (r *Resolver) titleGrants() ([]grant, error) {
+	var grants []grant
+
+	for _, earned := range r.character.Titles {
+		title, ok := r.reference.Titles[earned.TitleID]
+		if !ok {
+			return nil, fmt.Errorf("title \"%s\" not found", earned.TitleID)
+		}
+
+		var benefit *Option
+		for _, b := range title.Benefits {
+			if b.ID == earned.BenefitID {
+				benefit = &b
+				break
+			}
+		}
+		if benefit == nil {
+			return nil, fmt.Errorf("benefit \"%s\" for title \"%s\" not found", earned.BenefitID, earned.TitleID)
+		}
+
+		operations := []Operation{{
+			Type:     OperationTypeAddTitle,
+			Target:   TitlesValueName,
+			ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: title.ID, RefIDType: RefIDTypeTitle},
+		}}
+		operations = append(operations, benefit.Operations...)
+
+		// the title only applies while its prereqs are met
+		for i := range operations {
+			operations[i].Prereqs = append(slices.Clone(operations[i].Prereqs), title.Prereqs...)
+		}
+		choices := slices.Clone(benefit.Choices)
+		for i := range choices {
+			choices[i].Prereqs = append(slices.Clone(choices[i].Prereqs), title.Prereqs...)
+		}
+
+		grants = append(grants, grant{operations: operations, choices: choices})
+	}
+
+	return grants, nil
+}
+
+// treasureGrants looks up every item in the character's inventory, returning
+// the operations of the equipped items as grants
+func (r *Resolver) treasureGrants() ([]grant, error) {
+	var grants []grant
+
+	for _, item := range r.character.Inventory {
+		treasure, ok := r.reference.Treasures[item.TreasureID]
+		if !ok {
+			return nil, fmt.Errorf("treasure \"%s\" not found", item.TreasureID)
+		}
+
+		if !item.Equipped {
+			continue
+		}
+		if treasure.Type == TreasureTypeConsumable {
+			return nil, fmt.Errorf("treasure \"%s\" is a consumable and can't be equipped", item.TreasureID)
+		}
+
+		grants = append(grants, grant{operations: treasure.Operations})
+	}
+
+	return grants, nil
+}
+
+// setup parses the class, grants and decisions to generate the Operations that
+// must be evaluated to resolve the character sheet. Operations on the same
+// value apply in order: the class levels first, then the grants in the order
+// given, then whatever the character's features grant.
+func (r *Resolver) setup(class *Class, grants []grant) {
 	var operations []Operation
 
 	// iterate through levels map in order
@@ -133,23 +503,63 @@ func (r *Resolver) setup(class *Class) {
 			break
 		}
 
-		// add non-choice operations
-		operations = append(operations, levelDefinition.Operations...)
+		operations = append(operations, r.reduceGrant(levelDefinition.Operations, levelDefinition.Choices)...)
+		if r.error != nil {
+			return
+		}
+	}
 
-		// use decisions to convert choices into operations
-		for _, choice := range levelDefinition.Choices {
-			choiceOperations := r.reduceChoice(&choice)
-			if r.error != nil {
-				return
-			}
-			if choiceOperations != nil {
-				operations = append(operations, choiceOperations...)
-			}
+	// grant operations come after the class levels so they can modify the
+	// values the class sets, e.g. increasing max recoveries
+	for _, g := range grants {
+		operations = append(operations, r.reduceGrant(g.operations, g.choices)...)
+		if r.error != nil {
+			return
 		}
 	}
 
+	// features bring their own abilities, operations and choices
+	operations = append(operations, r.featureGrants(operations)...)
+	if r.error != nil {
+		return
+	}
+
+	// the level, default and derived values are set before anything modifies
+	// them
+	initial := []Operation{{
+		Type:     OperationTypeSet,
+		Target:   LevelValueName,
+		ValueRef: ValueRef{Type: ValueRefTypeInt, Value: r.character.Level},
+	}}
+	for _, target := range defaultValues {
+		initial = append(initial, Operation{
+			Type:     OperationTypeSet,
+			Target:   target,
+			ValueRef: ValueRef{Type: ValueRefTypeInt, Value: 0},
+		})
+	}
+	initial = append(initial, Operation{
+		Type:     OperationTypeSet,
+		Target:   SaveTargetValueName,
+		ValueRef: ValueRef{Type: ValueRefTypeInt, Value: SaveTargetBase},
+	}, Operation{
+		Type:     OperationTypeSet,
+		Target:   SizeValueName,
+		ValueRef: ValueRef{Type: ValueRefTypeString, Value: DefaultSize},
+	}, Operation{
+		Type:     OperationTypeSet,
+		Target:   SpeedValueName,
+		ValueRef: ValueRef{Type: ValueRefTypeInt, Value: DefaultSpeed},
+	})
+	initial = append(initial, derivedValues...)
+	operations = append(initial, operations...)
+
 	// add operations
 	for _, operation := range operations {
+		if operation.Target == "" {
+			r.error = fmt.Errorf("%s operation has no target", operation.Type)
+			return
+		}
 		r.operations[operation.Target] = append(r.operations[operation.Target], &operation)
 	}
 
@@ -161,6 +571,97 @@ func (r *Resolver) setup(class *Class) {
 	log.Println(pretty)
 }
 
+// featureGrants converts the abilities, operations and choices of every
+// feature the operations can add into operations, including features added by
+// those features in turn. Each feature is only expanded once, and its
+// operations apply only while the feature is on the sheet, so a feature added
+// in more than one way isn't applied twice.
+func (r *Resolver) featureGrants(operations []Operation) []Operation {
+	var result []Operation
+
+	err := r.reference.walkFeatures(operations, func(feature *Feature) []Operation {
+		if r.error != nil {
+			return nil
+		}
+
+		var featureOperations []Operation
+		for _, abilityID := range feature.Abilities {
+			featureOperations = append(featureOperations, r.reduceRefID(abilityID, RefIDTypeAbility))
+			if r.error != nil {
+				return nil
+			}
+		}
+		featureOperations = append(featureOperations, r.reduceGrant(feature.Operations, feature.Choices)...)
+		if r.error != nil {
+			return nil
+		}
+
+		// the feature must be on the sheet for anything it grants to apply
+		hasFeature := Assertion{
+			Type:    AssertionTypeRefArray,
+			RefType: RefIDTypeFeature,
+			Values:  []ValueRef{{Type: ValueRefTypeString, Value: feature.ID}},
+		}
+		for i := range featureOperations {
+			featureOperations[i].Prereqs = append(slices.Clone(featureOperations[i].Prereqs), hasFeature)
+		}
+
+		result = append(result, featureOperations...)
+		return featureOperations
+	})
+	if err != nil {
+		r.error = err
+		return nil
+	}
+	if r.error != nil {
+		return nil
+	}
+
+	return result
+}
+
+func isDerivedValue(node string) bool {
+	return slices.ContainsFunc(derivedValues, func(derived Operation) bool {
+		return derived.Target == node
+	})
+}
+
+// reduceGrant combines the non-choice operations of a grant with the
+// operations produced by its choices
+func (r *Resolver) reduceGrant(operations []Operation, choices []Choice) []Operation {
+	// add non-choice operations
+	result := append([]Operation{}, operations...)
+
+	// use decisions to convert choices into operations
+	for _, choice := range choices {
+		choiceOperations := r.reduceChoice(&choice)
+		if r.error != nil {
+			return nil
+		}
+		if choiceOperations != nil {
+			result = append(result, choiceOperations...)
+		}
+	}
+
+	return result
+}
+
+// reduceOption converts a selected Option, including any choices nested
+// within it, into a set of Operations carrying the prereqs of its Choice
+func (r *Resolver) reduceOption(choice *Choice, option *Option) []Operation {
+	operations := r.reduceGrant(option.Operations, option.Choices)
+	if r.error != nil {
+		return nil
+	}
+
+	// apply the choice prereqs to the option operations
+	for i := range operations {
+		operations[i].Prereqs = append(slices.Clone(operations[i].Prereqs), choice.Prereqs...)
+	}
+
+	return operations
+}
+
 // reduceChoice converts a Choice into a set of Operations
 func (r *Resolver) reduceChoice(choice *Choice) []Operation {
 	var operations []Operation
@@ -185,23 +686,89 @@ func (r *Resolver) reduceChoice(choice *Choice) []Operation {
 			return nil
 		}
 
-		// apply the choice prereqs to the option operations
-		for _, operation := range option.Operations {
-			operation.Prereqs = append(operation.Prereqs, choice.Prereqs...)
-			operations = append(operations, operation)
+		operations = append(operations, r.reduceOption(choice, option)...)
+		if r.error != nil {
+			return nil
 		}
 	case ChoiceTypeRefSelect:
+		if len(choice.RefIDs) > 0 && !slices.Contains(choice.RefIDs, decision.RefID) {
+			r.error = fmt.Errorf("%s \"%s\" for choice \"%s\" is not one of %v", choice.RefType, decision.RefID, choice.ID, choice.RefIDs)
+			return nil
+		}
+
+		// skills may be limited to certain skill groups
+		if choice.RefType == RefIDTypeSkill && len(choice.RefGroups) > 0 {
+			skill := r.reference.Skills[decision.RefID]
+			if !slices.Contains(choice.RefGroups, skill.Group) {
+				r.error = fmt.Errorf("skill \"%s\" for choice \"%s\" is not in groups %v", decision.RefID, choice.ID, choice.RefGroups)
+				return nil
+			}
+		}
+
 		// reduce the referenced value into an operation
 		operation := r.reduceRefID(decision.RefID, choice.RefType)
 		if r.error != nil {
 			return nil
 		}
+		operation.Prereqs = choice.Prereqs
 		operations = append(operations, operation)
+
+		if choice.RecordTarget != "" {
+			operations = append(operations, Operation{
+				Type:     OperationTypeSet,
+				Target:   choice.RecordTarget,
+				ValueRef: ValueRef{Type: ValueRefTypeString, Value: decision.RefID},
+				Prereqs:  choice.Prereqs,
+			})
+		}
+	case ChoiceTypePointBuy:
+		// get each selected option, keeping within the point budget
+		var spent int
+		for i, optionID := range decision.OptionIDs {
+			if slices.Contains(decision.OptionIDs[:i], optionID) {
+				r.error = fmt.Errorf("option \"%s\" for choice \"%s\" is picked more than once", optionID, choice.ID)
+				return nil
+			}
+
+			var option *Option
+			for _, o := range choice.Options {
+				if o.ID == optionID {
+					option = &o
+					break
+				}
+			}
+			if option == nil {
+				r.error = fmt.Errorf("option \"%s\" for choice \"%s\" not found", optionID, choice.ID)
+				return nil
+			}
+
+			spent += option.Cost
+			if spent > choice.Points {
+				r.error = fmt.Errorf("options for choice \"%s\" cost more than %d points", choice.ID, choice.Points)
+				return nil
+			}
+
+			operations = append(operations, r.reduceOption(choice, option)...)
+			if r.error != nil {
+				return nil
+			}
+		}
+	case ChoiceTypeCharacteristicArray:
+		operations = append(operations, r.reduceCharacteristicArray(choice, &decision)...)
+		if r.error != nil {
+			return nil
+		}
 	case ChoiceTypeInput:
+		// inputs set their target unless the choice says otherwise
+		operationType := choice.OperationType
+		if operationType == "" {
+			operationType = OperationTypeSet
+		}
 		operations = append(operations, Operation{
-			Type:     OperationTypeSet,
+			Type:     operationType,
 			Target:   choice.Target,
 			ValueRef: decision.Value,
+			Prereqs:  choice.Prereqs,
 		})
 	default:
 		r.error = fmt.Errorf("unknown choice type: %s", choice.Type)
@@ -211,10 +778,56 @@ func (r *Resolver) reduceChoice(choice *Choice) []Operation {
 	return operations
 }
 
+// reduceCharacteristicArray checks that the assigned scores match one of the
+// arrays of the choice, and converts them into Operations setting each
+// characteristic
+func (r *Resolver) reduceCharacteristicArray(choice *Choice, decision *Decision) []Operation {
+	characteristics := choice.Characteristics
+	if len(characteristics) == 0 {
+		characteristics = Characteristics
+	}
+
+	// every characteristic must be assigned exactly once
+	if len(decision.Assignments) != len(characteristics) {
+		r.error = fmt.Errorf("choice \"%s\" must assign %v", choice.ID, characteristics)
+		return nil
+	}
+	scores := make([]int, 0, len(characteristics))
+	for _, characteristic := range characteristics {
+		score, ok := decision.Assignments[characteristic]
+		if !ok {
+			r.error = fmt.Errorf("choice \"%s\" must assign %v", choice.ID, characteristics)
+			return nil
+		}
+		scores = append(scores, score)
+	}
+
+	// the scores must be some arrangement of one of the arrays
+	slices.Sort(scores)
+	matched := slices.ContainsFunc(choice.Arrays, func(array []int) bool {
+		return slices.Equal(scores, slices.Sorted(slices.Values(array)))
+	})
+	if !matched {
+		r.error = fmt.Errorf("assignments for choice \"%s\" don't match any of %v", choice.ID, choice.Arrays)
+		return nil
+	}
+
+	var operations []Operation
+	for _, characteristic := range characteristics {
+		operations = append(operations, Operation{
+			Type:     OperationTypeSet,
+			Target:   CharacteristicsValuePrefix + characteristic,
+			ValueRef: ValueRef{Type: ValueRefTypeInt, Value: decision.Assignments[characteristic]},
+			Prereqs:  choice.Prereqs,
+		})
+	}
+	return operations
+}
+
 // reduceRefID resolves a reference ID into an operation to add that referenced
 // value to the sheet
-// NOTE: this excludes the "skill group" ref id since those are never added to
-// a character sheet
+// NOTE: a "skill group" ref id adds every skill in the group, since the group
+// itself is never added to a character sheet
 func (r *Resolver) reduceRefID(refID string, refIDType string) Operation {
 	switch refIDType {
 	case RefIDTypeAbility:
@@ -277,6 +890,17 @@ func (r *Resolver) reduceRefID(refID string, refIDType string) Operation {
 			Target:   FeaturesValueName,
 			ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: refID, RefIDType: refIDType},
 		}
+	case RefIDTypeHeroicResource:
+		_, ok := r.reference.HeroicResources[refID]
+		if !ok {
+			r.error = fmt.Errorf("heroic resource \"%s\" not found", refID)
+			return Operation{}
+		}
+		return Operation{
+			Type:     OperationTypeSet,
+			Target:   HeroicResourceValueName,
+			ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: refID, RefIDType: refIDType},
+		}
 	case RefIDTypeKit:
 		_, ok := r.reference.Kits[refID]
 		if !ok {
@@ -288,14 +912,47 @@ func (r *Resolver) reduceRefID(refID string, refIDType string) Operation {
 			Target:   KitsValueName,
 			ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: refID, RefIDType: refIDType},
 		}
+	case RefIDTypeLanguage:
+		_, ok := r.reference.Languages[refID]
+		if !ok {
+			r.error = fmt.Errorf("language \"%s\" not found", refID)
+			return Operation{}
+		}
+		return Operation{
+			Type:     OperationTypeAddLanguage,
+			Target:   LanguagesValueName,
+			ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: refID, RefIDType: refIDType},
+		}
+	case RefIDTypeResource:
+		_, ok := r.reference.Resources[refID]
+		if !ok {
+			r.error = fmt.Errorf("resource \"%s\" not found", refID)
+			return Operation{}
+		}
+		return Operation{
+			Type:     OperationTypeGrantResource,
+			Target:   ResourcesValueName,
+			ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: refID, RefIDType: refIDType},
+		}
 	case RefIDTypeSkill:
 		_, ok := r.reference.Skills[refID]
 		if !ok {
-			r.error = fmt.Errorf("skill \"%s\" not found", refID)
+			r.error = fmt.Errorf("skill \"%s\" not found", refID)
+			return Operation{}
+		}
+		return Operation{
+			Type:     OperationTypeAddSkill,
+			Target:   SkillsValueName,
+			ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: refID, RefIDType: refIDType},
+		}
+	case RefIDTypeSkillGroup:
+		_, ok := r.reference.SkillGroups[refID]
+		if !ok {
+			r.error = fmt.Errorf("skill group \"%s\" not found", refID)
 			return Operation{}
 		}
 		return Operation{
-			Type:     OperationTypeAddSkill,
+			Type:     OperationTypeAddSkillGroup,
 			Target:   SkillsValueName,
 			ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: refID, RefIDType: refIDType},
 		}
@@ -360,22 +1017,132 @@ func (r *Resolver) EvaluateOperation(operation *Operation) {
 	}
 	r.trace.Pop()
 
+	// remember the characteristic before it changes so it can be capped
+	previous, hadPrevious := r.values[operation.Target].(int)
+
 	switch operation.Type {
 	case OperationTypeSet:
 		r.values[operation.Target] = result
-	case OperationTypeAddSkill:
+	case OperationTypeAdd, OperationTypeSubtract:
+		current, ok := r.values[operation.Target]
+		if !ok {
+			r.error = fmt.Errorf("cannot %s \"%s\" before it has a value", operation.Type, operation.Target)
+			return
+		}
+
+		currentInt, ok := current.(int)
+		if !ok {
+			r.error = fmt.Errorf("cannot %s \"%s\", which is not an int", operation.Type, operation.Target)
+			return
+		}
+		amount, ok := result.(int)
+		if !ok {
+			r.error = fmt.Errorf("cannot %s %T to \"%s\"", operation.Type, result, operation.Target)
+			return
+		}
+
+		if operation.Type == OperationTypeSubtract {
+			amount = -amount
+		}
+		r.values[operation.Target] = currentInt + amount
+	case OperationTypeGrantImmunity, OperationTypeGrantWeakness:
+		valueName := ImmunitiesValueName
+		if operation.Type == OperationTypeGrantWeakness {
+			valueName = WeaknessesValueName
+		}
+
+		damageType, ok := strings.CutPrefix(operation.Target, valueName+".")
+		if !ok || !slices.Contains(DamageTypes, damageType) {
+			r.error = fmt.Errorf("%s target \"%s\" is not %s.<damage type>", operation.Type, operation.Target, valueName)
+			return
+		}
+
+		amount, ok := result.(int)
+		if !ok {
+			r.error = fmt.Errorf("cannot %s of %T to \"%s\"", operation.Type, result, operation.Target)
+			return
+		}
+
+		// immunities and weaknesses of the same type don't stack, the highest
+		// value applies
+		current, ok := r.values[operation.Target].(int)
+		if !ok || amount > current {
+			r.values[operation.Target] = amount
+		}
+	case OperationTypeGrantMovement:
+		mode, ok := strings.CutPrefix(operation.Target, MovementModesValueName+".")
+		if !ok || !slices.Contains(MovementModes, mode) {
+			r.error = fmt.Errorf("%s target \"%s\" is not %s.<movement mode>", operation.Type, operation.Target, MovementModesValueName)
+			return
+		}
+
+		speed, ok := result.(int)
+		if !ok {
+			r.error = fmt.Errorf("cannot %s of %T to \"%s\"", operation.Type, result, operation.Target)
+			return
+		}
+
+		// a mode granted more than once uses the fastest speed, later
+		// operations can still add to it
+		current, ok := r.values[operation.Target].(int)
+		if !ok || speed > current {
+			r.values[operation.Target] = speed
+		}
+	case OperationTypeAddEdge, OperationTypeAddBane:
+		// edges and banes are tracked per skill
+		valueName := EdgesValueName
+		if operation.Type == OperationTypeAddBane {
+			valueName = BanesValueName
+		}
+
 		skillID := result.(string)
 
-		_, ok := r.values[SkillsValueName]
+		_, ok := r.values[valueName]
 		if !ok {
-			r.values[SkillsValueName] = make([]string, 0)
+			r.values[valueName] = make([]string, 0)
 		}
 
-		skills := r.values[SkillsValueName].([]string)
+		skills := r.values[valueName].([]string)
 		if !slices.Contains(skills, skillID) {
 			skills = append(skills, skillID)
 		}
-		r.values[SkillsValueName] = skills
+		r.values[valueName] = skills
+	case OperationTypeAddSkill:
+		r.addSkill(result.(string))
+	case OperationTypeAddSkillGroup:
+		groupID := result.(string)
+
+		// grant every skill in the group, in a stable order
+		var skillIDs []string
+		for skillID, skill := range r.reference.Skills {
+			if skill.Group == groupID {
+				skillIDs = append(skillIDs, skillID)
+			}
+		}
+		sort.Strings(skillIDs)
+
+		for _, skillID := range skillIDs {
+			r.addSkill(skillID)
+		}
+	case OperationTypeAddLanguage:
+		language := result.(string)
+
+		// languages given as plain strings still have to exist
+		if _, ok := r.reference.Languages[language]; !ok {
+			r.error = fmt.Errorf("language \"%s\" not found", language)
+			return
+		}
+
+		_, ok := r.values[LanguagesValueName]
+		if !ok {
+			r.values[LanguagesValueName] = make([]string, 0)
+		}
+
+		languages := r.values[LanguagesValueName].([]string)
+		if !slices.Contains(languages, language) {
+			languages = append(languages, language)
+		}
+		r.values[LanguagesValueName] = languages
 	case OperationTypeAddDomain:
 		domainID := result.(string)
 
@@ -398,10 +1165,64 @@ func (r *Resolver) EvaluateOperation(operation *Operation) {
 		}
 
 		features := r.values[FeaturesValueName].([]string)
-		if !slices.Contains(features, featureID) {
-			features = append(features, featureID)
+		if slices.Contains(features, featureID) {
+			break
+		}
+
+		// a feature replaced by an upgrade leaves the feature list before
+		// anything it grants applies, so only the record of it remains
+		superseded, ok := r.values[SupersededValueName].(map[string]string)
+		if !ok {
+			superseded = make(map[string]string)
+		}
+		if _, ok := superseded[featureID]; ok {
+			break
+		}
+		if upgrade := slices.IndexFunc(features, func(id string) bool {
+			return slices.Contains(r.reference.Features[id].Supersedes, featureID)
+		}); upgrade >= 0 {
+			superseded[featureID] = features[upgrade]
+			r.values[SupersededValueName] = superseded
+			break
+		}
+		features = slices.DeleteFunc(features, func(id string) bool {
+			if slices.Contains(r.reference.Features[featureID].Supersedes, id) {
+				superseded[id] = featureID
+				return true
+			}
+			return false
+		})
+		if len(superseded) > 0 {
+			r.values[SupersededValueName] = superseded
+		}
+
+		r.values[FeaturesValueName] = append(features, featureID)
+	case OperationTypeAddTitle:
+		titleID := result.(string)
+
+		_, ok := r.values[TitlesValueName]
+		if !ok {
+			r.values[TitlesValueName] = make([]string, 0)
+		}
+
+		titles := r.values[TitlesValueName].([]string)
+		if !slices.Contains(titles, titleID) {
+			titles = append(titles, titleID)
+		}
+		r.values[TitlesValueName] = titles
+	case OperationTypeGrantResource:
+		resourceID := result.(string)
+
+		_, ok := r.values[ResourcesValueName]
+		if !ok {
+			r.values[ResourcesValueName] = make([]string, 0)
+		}
+
+		resources := r.values[ResourcesValueName].([]string)
+		if !slices.Contains(resources, resourceID) {
+			resources = append(resources, resourceID)
 		}
-		r.values[FeaturesValueName] = features
+		r.values[ResourcesValueName] = resources
 	case OperationTypeAddKit:
 		kitID := result.(string)
 
@@ -451,113 +1272,131 @@ func (r *Resolver) EvaluateOperation(operation *Operation) {
 		r.error = fmt.Errorf("unknown operation type: %s", operation.Type)
 		return
 	}
+
+	// class specific values must match the type the class declares
+	if strings.HasPrefix(operation.Target, ClassValuePrefix) {
+		r.checkClassValue(operation.Target)
+	}
+
+	// sizes must be written like "1M" or "2"
+	if operation.Target == SizeValueName {
+		size, _ := r.values[SizeValueName].(string)
+		if _, err := model.ParseSize(size); err != nil {
+			r.error = err
+			return
+		}
+	}
+
+	// characteristics can't be increased past the cap for the echelon
+	if strings.HasPrefix(operation.Target, CharacteristicsValuePrefix) {
+		r.capCharacteristic(operation.Target, previous, hadPrevious)
+	}
 }
 
-// TODO: handle melee and ranged damage bonuses, and ranged distance bonus
-func (r *Resolver) handleKitOperations(kitID string) {
-	kit, ok := r.reference.Kits[kitID]
+// capCharacteristic limits a characteristic to the cap for the character's
+// echelon, unless it was already higher before the operation
+func (r *Resolver) capCharacteristic(target string, previous int, hadPrevious bool) {
+	value, ok := r.values[target].(int)
 	if !ok {
-		r.error = fmt.Errorf("kit \"%s\" not found", kitID)
+		r.error = fmt.Errorf("characteristic \"%s\" must be an int, got %T", target, r.values[target])
 		return
 	}
 
-	if kit.Bonuses.StaminaBonus != 0 {
-		// prepare an operation to add the bonus
-		operation := Operation{
-			Type:   OperationTypeSet,
-			Target: "health.max_stamina",
-			ValueRef: ValueRef{Type: ValueRefTypeExpression, Value: &Expression{
-				Type: ExprTypeAdd,
-				Args: []ValueRef{
-					{Type: ValueRefTypeID, Value: "health.max_stamina"},
-					{Type: ValueRefTypeInt, Value: kit.Bonuses.StaminaBonus},
-				},
-			}},
-		}
+	limit := CharacteristicCaps[Echelon(r.character.Level)]
+	if hadPrevious {
+		limit = max(limit, previous)
+	}
 
-		// if stamina was already calculated, add the bonus now
-		if r.visited["health.max_stamina"] {
-			r.trace.Push(operation)
-			r.EvaluateOperation(&operation)
-			if r.error != nil {
-				return
-			}
-			r.trace.Pop()
-		} else {
-			r.operations["health.max_stamina"] = append(r.operations["health.max_stamina"], &operation)
-		}
+	if value > limit {
+		log.Printf("capping %s at %d\n", target, limit)
+		r.values[target] = limit
 	}
+}
 
-	if kit.Bonuses.SpeedBonus != 0 {
-		// prepare an operation to add the bonus
-		operation := Operation{
-			Type:   OperationTypeSet,
-			Target: "movement.speed",
-			ValueRef: ValueRef{Type: ValueRefTypeExpression, Value: &Expression{
-				Type: ExprTypeAdd,
-				Args: []ValueRef{
-					{Type: ValueRefTypeID, Value: "movement.speed"},
-					{Type: ValueRefTypeInt, Value: kit.Bonuses.SpeedBonus},
-				},
-			}},
-		}
+// checkClassValue verifies that a class specific value is declared by the
+// class extension and has the declared type
+func (r *Resolver) checkClassValue(target string) {
+	name := strings.TrimPrefix(target, ClassValuePrefix)
 
-		// if speed was already calculated, add the bonus now
-		if r.visited["movement.speed"] {
-			r.trace.Push(operation)
-			r.EvaluateOperation(&operation)
-			if r.error != nil {
-				return
-			}
-			r.trace.Pop()
-		} else {
-			r.operations["movement.speed"] = append(r.operations["movement.speed"], &operation)
-		}
+	valueType, ok := r.class.Extension[name]
+	if !ok {
+		r.error = fmt.Errorf("class \"%s\" does not declare class value \"%s\"", r.class.ID, name)
+		return
 	}
 
-	if kit.Bonuses.StabilityBonus != 0 {
-		// prepare an operation to add the bonus
-		operation := Operation{
-			Type:   OperationTypeSet,
-			Target: "movement.stability",
-			ValueRef: ValueRef{Type: ValueRefTypeExpression, Value: &Expression{
-				Type: ExprTypeAdd,
-				Args: []ValueRef{
-					{Type: ValueRefTypeID, Value: "movement.stability"},
-					{Type: ValueRefTypeInt, Value: kit.Bonuses.StabilityBonus},
-				},
-			}},
-		}
+	value := r.values[target]
+	switch valueType {
+	case ClassValueTypeInt:
+		_, ok = value.(int)
+	case ClassValueTypeString:
+		_, ok = value.(string)
+	case ClassValueTypeStringList:
+		_, ok = value.([]string)
+	default:
+		r.error = fmt.Errorf("class \"%s\" declares class value \"%s\" with unknown type: %s", r.class.ID, name, valueType)
+		return
+	}
+	if !ok {
+		r.error = fmt.Errorf("class value \"%s\" must be %s, got %T", name, valueType, value)
+	}
+}
 
-		// if stability was already calculated, add the bonus now
-		if r.visited["movement.stability"] {
-			r.trace.Push(operation)
-			r.EvaluateOperation(&operation)
-			if r.error != nil {
-				return
-			}
-			r.trace.Pop()
-		} else {
-			r.operations["movement.stability"] = append(r.operations["movement.stability"], &operation)
-		}
+// addSkill adds a skill to the sheet, skipping skills that are already held
+func (r *Resolver) addSkill(skillID string) {
+	_, ok := r.values[SkillsValueName]
+	if !ok {
+		r.values[SkillsValueName] = make([]string, 0)
+	}
+
+	skills := r.values[SkillsValueName].([]string)
+	if !slices.Contains(skills, skillID) {
+		skills = append(skills, skillID)
+	}
+	r.values[SkillsValueName] = skills
+}
+
+// handleKitOperations applies the bonuses of a kit and adds its abilities
+// NOTE: the ranged distance bonus isn't applied, as the sheet has no distances
+// of its own to add it to
+func (r *Resolver) handleKitOperations(kitID string) {
+	kit, ok := r.reference.Kits[kitID]
+	if !ok {
+		r.error = fmt.Errorf("kit \"%s\" not found", kitID)
+		return
+	}
+
+	bonuses := []struct {
+		target string
+		value  ValueRef
+		skip   bool
+	}{
+		{"health.max_stamina", ValueRef{Type: ValueRefTypeInt, Value: kit.Bonuses.StaminaBonus}, kit.Bonuses.StaminaBonus == 0},
+		{"movement.speed", ValueRef{Type: ValueRefTypeInt, Value: kit.Bonuses.SpeedBonus}, kit.Bonuses.SpeedBonus == 0},
+		{"movement.stability", ValueRef{Type: ValueRefTypeInt, Value: kit.Bonuses.StabilityBonus}, kit.Bonuses.StabilityBonus == 0},
+		{"movement.disengage", ValueRef{Type: ValueRefTypeInt, Value: kit.Bonuses.DisengageBonus}, kit.Bonuses.DisengageBonus == 0},
+		{"damage_bonuses.melee", kit.Bonuses.MeleeDamageBonus.valueRef(), kit.Bonuses.MeleeDamageBonus == KitDamageBonus{}},
+		{"damage_bonuses.ranged", kit.Bonuses.RangedDamageBonus.valueRef(), kit.Bonuses.RangedDamageBonus == KitDamageBonus{}},
 	}
+	for _, bonus := range bonuses {
+		if bonus.skip {
+			continue
+		}
 
-	if kit.Bonuses.DisengageBonus != 0 {
 		// prepare an operation to add the bonus
 		operation := Operation{
 			Type:   OperationTypeSet,
-			Target: "movement.disengage",
+			Target: bonus.target,
 			ValueRef: ValueRef{Type: ValueRefTypeExpression, Value: &Expression{
 				Type: ExprTypeAdd,
 				Args: []ValueRef{
-					{Type: ValueRefTypeID, Value: "movement.disengage"},
-					{Type: ValueRefTypeInt, Value: kit.Bonuses.DisengageBonus},
+					{Type: ValueRefTypeID, Value: bonus.target},
+					bonus.value,
 				},
 			}},
 		}
 
-		// if disengage was already calculated, add the bonus now
-		if r.visited["movement.disengage"] {
+		// if the target was already calculated, add the bonus now
+		if r.visited[bonus.target] {
 			r.trace.Push(operation)
 			r.EvaluateOperation(&operation)
 			if r.error != nil {
@@ -565,7 +1404,7 @@ func (r *Resolver) handleKitOperations(kitID string) {
 			}
 			r.trace.Pop()
 		} else {
-			r.operations["movement.disengage"] = append(r.operations["movement.disengage"], &operation)
+			r.operations[bonus.target] = append(r.operations[bonus.target], &operation)
 		}
 	}
 
@@ -640,6 +1479,13 @@ func (r *Resolver) EvaluateValueRef(valueRef *ValueRef) any {
 		}
 
 		return exprValue
+	case ValueRefTypeLevelTable:
+		entry := r.levelTableEntry(valueRef.Value.(*LevelTable))
+		if r.error != nil {
+			return nil
+		}
+
+		return r.EvaluateValueRef(&entry.Value)
 	case ValueRefTypeRefID:
 		// verify the referenced entity exists
 		switch valueRef.RefIDType {
@@ -686,6 +1532,13 @@ func (r *Resolver) EvaluateValueRef(valueRef *ValueRef) any {
 				return nil
 			}
 			return valueRef.Value.(string)
+		case RefIDTypeHeroicResource:
+			_, ok := r.reference.HeroicResources[valueRef.Value.(string)]
+			if !ok {
+				r.error = fmt.Errorf("heroic resource \"%s\" not found", valueRef.Value.(string))
+				return nil
+			}
+			return valueRef.Value.(string)
 		case RefIDTypeKit:
 			_, ok := r.reference.Kits[valueRef.Value.(string)]
 			if !ok {
@@ -693,6 +1546,20 @@ func (r *Resolver) EvaluateValueRef(valueRef *ValueRef) any {
 				return nil
 			}
 			return valueRef.Value.(string)
+		case RefIDTypeLanguage:
+			_, ok := r.reference.Languages[valueRef.Value.(string)]
+			if !ok {
+				r.error = fmt.Errorf("language \"%s\" not found", valueRef.Value.(string))
+				return nil
+			}
+			return valueRef.Value.(string)
+		case RefIDTypeResource:
+			_, ok := r.reference.Resources[valueRef.Value.(string)]
+			if !ok {
+				r.error = fmt.Errorf("resource \"%s\" not found", valueRef.Value.(string))
+				return nil
+			}
+			return valueRef.Value.(string)
 		case RefIDTypeSkill:
 			_, ok := r.reference.Skills[valueRef.Value.(string)]
 			if !ok {
@@ -700,6 +1567,27 @@ func (r *Resolver) EvaluateValueRef(valueRef *ValueRef) any {
 				return nil
 			}
 			return valueRef.Value.(string)
+		case RefIDTypeSkillGroup:
+			_, ok := r.reference.SkillGroups[valueRef.Value.(string)]
+			if !ok {
+				r.error = fmt.Errorf("skill group \"%s\" not found", valueRef.Value.(string))
+				return nil
+			}
+			return valueRef.Value.(string)
+		case RefIDTypeTitle:
+			_, ok := r.reference.Titles[valueRef.Value.(string)]
+			if !ok {
+				r.error = fmt.Errorf("title \"%s\" not found", valueRef.Value.(string))
+				return nil
+			}
+			return valueRef.Value.(string)
+		case RefIDTypeTreasure:
+			_, ok := r.reference.Treasures[valueRef.Value.(string)]
+			if !ok {
+				r.error = fmt.Errorf("treasure \"%s\" not found", valueRef.Value.(string))
+				return nil
+			}
+			return valueRef.Value.(string)
 		default:
 			r.error = fmt.Errorf("invalid refid type: %s", valueRef.RefIDType)
 			return nil
@@ -710,6 +1598,33 @@ func (r *Resolver) EvaluateValueRef(valueRef *ValueRef) any {
 	}
 }
 
+// levelTableEntry finds the entry of a LevelTable that applies to the
+// character, which is the one with the highest threshold reached
+func (r *Resolver) levelTableEntry(table *LevelTable) *LevelTableEntry {
+	scale := r.character.Level
+	switch table.Scale {
+	case LevelTableScaleLevel, "":
+	case LevelTableScaleEchelon:
+		scale = Echelon(r.character.Level)
+	default:
+		r.error = fmt.Errorf("invalid level table scale: %s", table.Scale)
+		return nil
+	}
+
+	var found *LevelTableEntry
+	for i := range table.Entries {
+		entry := &table.Entries[i]
+		if entry.Threshold <= scale && (found == nil || entry.Threshold > found.Threshold) {
+			found = entry
+		}
+	}
+	if found == nil {
+		r.error = fmt.Errorf("level table has no entry for %d", scale)
+		return nil
+	}
+	return found
+}
+
 func (r *Resolver) EvaluateExpression(expression *Expression) int {
 	switch expression.Type {
 	case ExprTypeAdd:
@@ -761,6 +1676,65 @@ func (r *Resolver) EvaluateExpression(expression *Expression) int {
 
 		result = arg1Int - arg2Int
 
+		return result
+	case ExprTypeDivide:
+		if len(expression.Args) != 2 {
+			r.error = fmt.Errorf("divide requires exactly two arguments")
+			return 0
+		}
+
+		arg1 := r.EvaluateValueRef(&expression.Args[0])
+		if r.error != nil {
+			return 0
+		}
+
+		arg2 := r.EvaluateValueRef(&expression.Args[1])
+		if r.error != nil {
+			return 0
+		}
+
+		arg1Int, ok := arg1.(int)
+		if !ok {
+			r.error = fmt.Errorf("first argument is not an int")
+			return 0
+		}
+
+		arg2Int, ok := arg2.(int)
+		if !ok {
+			r.error = fmt.Errorf("second argument is not an int")
+			return 0
+		}
+
+		if arg2Int == 0 {
+			r.error = fmt.Errorf("cannot divide by zero")
+			return 0
+		}
+
+		// integer division, so a positive result is rounded down
+		return arg1Int / arg2Int
+	case ExprTypeMax:
+		if len(expression.Args) == 0 {
+			r.error = fmt.Errorf("max requires at least one argument")
+			return 0
+		}
+
+		var result int
+		for i, arg := range expression.Args {
+			value := r.EvaluateValueRef(&arg)
+			if r.error != nil {
+				return 0
+			}
+
+			valueInt, ok := value.(int)
+			if !ok {
+				r.error = fmt.Errorf("argument is not an int")
+				return 0
+			}
+
+			if i == 0 || valueInt > result {
+				result = valueInt
+			}
+		}
 		return result
 	default:
 		r.error = fmt.Errorf("unknown expression type: %s", expression.Type)
@@ -851,6 +1825,12 @@ func (r *Resolver) checkAssertion(assertion *Assertion) bool {
 			return r.checkArrayForIDs(FeaturesValueName, &assertion.Values)
 		case RefIDTypeKit:
 			return r.checkArrayForIDs(KitsValueName, &assertion.Values)
+		case RefIDTypeLanguage:
+			return r.checkArrayForIDs(LanguagesValueName, &assertion.Values)
+		case RefIDTypeResource:
+			return r.checkArrayForIDs(ResourcesValueName, &assertion.Values)
+		case RefIDTypeTitle:
+			return r.checkArrayForIDs(TitlesValueName, &assertion.Values)
 		case RefIDTypeSkill:
 			return r.checkArrayForIDs(SkillsValueName, &assertion.Values)
 		default:
@@ -928,8 +1908,9 @@ func (r *Resolver) checkAssertion(assertion *Assertion) bool {
 }
 
 func (r *Resolver) checkArrayForIDs(arrayID string, valueRefs *[]ValueRef) bool {
-	refArray, ok := r.values[arrayID]
-	if !ok {
+	// the array is only read once every operation on it has applied, since an
+	// array partway through evaluation could be missing IDs added later
+	if !r.completed[arrayID] {
 		// check for pending operations
 		ops, ok := r.operations[arrayID]
 		if !ok {
@@ -937,6 +1918,13 @@ func (r *Resolver) checkArrayForIDs(arrayID string, valueRefs *[]ValueRef) bool
 			return false
 		}
 
+		// an operation on the array itself may check the IDs added before it,
+		// but anything else reading it mid-evaluation is a circular reference
+		if r.visited[arrayID] && r.trace.Node() != arrayID {
+			r.error = fmt.Errorf("circular reference detected for node \"%s\"", arrayID)
+			return false
+		}
+
 		// evaluate the node, then proceed
 		for _, op := range ops {
 			log.Println(*op)
@@ -949,12 +1937,12 @@ func (r *Resolver) checkArrayForIDs(arrayID string, valueRefs *[]ValueRef) bool
 			return false
 		}
 		r.trace.Pop()
+	}
 
-		refArray, ok = r.values[arrayID]
-		if !ok {
-			log.Printf("assertion false: %s array not found after evaluation\n", arrayID)
-			return false
-		}
+	refArray, ok := r.values[arrayID]
+	if !ok {
+		log.Printf("assertion false: %s array not found after evaluation\n", arrayID)
+		return false
 	}
 
 	for _, valueRef := range *valueRefs {
@@ -0,0 +1,505 @@
+package rules
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JamisonHubbard/dsbeyond/model"
+)
+
+func TestMain(m *testing.M) {
+	// the resolver logs every operation, which drowns out test failures
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// testClassID is the class every test reference is built around
+const testClassID = "tester"
+
+// testReference returns a Reference with a single class whose first level
+// sets the stamina the derived values need, followed by the operations and
+// choices given
+func testReference(operations []Operation, choices []Choice) *Reference {
+	levelOne := ClassLevel{
+		Operations: append([]Operation{{
+			Type:     OperationTypeSet,
+			Target:   "health.max_stamina",
+			ValueRef: ValueRef{Type: ValueRefTypeInt, Value: 21},
+		}}, operations...),
+		Choices: choices,
+	}
+	return &Reference{
+		Classes: map[string]Class{
+			testClassID: {ID: testClassID, Name: "Tester", Levels: map[int]ClassLevel{1: levelOne}},
+		},
+	}
+}
+
+// resolveTest resolves a character of the test class at the given level
+func resolveTest(reference *Reference, level int, decisions map[string]Decision) (model.Sheet, error) {
+	character := model.Character{ID: "test", ClassID: testClassID, Level: level}
+	return NewResolver(character, decisions, reference).Resolve()
+}
+
+// expectError fails the test unless err is set and contains want
+func expectError(t *testing.T, err error, want string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected an error containing %q, got none", want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected an error containing %q, got %q", want, err)
+	}
+}
+
+func TestCharacteristicArray(t *testing.T) {
+	choice := Choice{
+		ID:              "starting_characteristics",
+		Type:            ChoiceTypeCharacteristicArray,
+		Characteristics: []string{"agility", "reason", "intuition"},
+		Arrays:          [][]int{{2, -1, -1}, {1, 1, -1}},
+	}
+	reference := testReference(nil, []Choice{choice})
+
+	tests := []struct {
+		name        string
+		assignments map[string]int
+		want        model.Characteristics
+		wantErr     string
+	}{
+		{
+			name:        "valid assignment",
+			assignments: map[string]int{"agility": -1, "reason": 2, "intuition": -1},
+			want:        model.Characteristics{Agility: -1, Reason: 2, Intuition: -1},
+		},
+		{
+			name:        "any order of an array",
+			assignments: map[string]int{"agility": 1, "reason": -1, "intuition": 1},
+			want:        model.Characteristics{Agility: 1, Reason: -1, Intuition: 1},
+		},
+		{
+			name:        "scores from no array",
+			assignments: map[string]int{"agility": 2, "reason": 1, "intuition": -1},
+			wantErr:     "don't match any of",
+		},
+		{
+			name:        "unknown characteristic",
+			assignments: map[string]int{"agility": 2, "reason": -1, "luck": -1},
+			wantErr:     "must assign",
+		},
+		{
+			name:        "missing characteristic",
+			assignments: map[string]int{"agility": 2, "reason": -1},
+			wantErr:     "must assign",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sheet, err := resolveTest(reference, 1, map[string]Decision{
+				choice.ID: {ChoiceID: choice.ID, Assignments: test.assignments},
+			})
+			if test.wantErr != "" {
+				expectError(t, err, test.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to resolve: %s", err)
+			}
+			if sheet.Characteristics != test.want {
+				t.Errorf("characteristics are %+v, want %+v", sheet.Characteristics, test.want)
+			}
+		})
+	}
+}
+
+func TestCharacteristicCap(t *testing.T) {
+	tests := []struct {
+		name  string
+		level int
+		start int
+		add   int
+		want  int
+	}{
+		{name: "within the 1st echelon cap", level: 1, start: 1, add: 1, want: 2},
+		{name: "clamped at the 1st echelon cap", level: 1, start: 2, add: 1, want: 2},
+		{name: "clamped at the 2nd echelon cap", level: 4, start: 2, add: 2, want: 3},
+		{name: "clamped at the 4th echelon cap", level: 10, start: 4, add: 3, want: 5},
+		{name: "set above the cap", level: 1, start: 3, add: 0, want: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reference := testReference([]Operation{
+				{Type: OperationTypeSet, Target: "characteristics.might", ValueRef: ValueRef{Type: ValueRefTypeInt, Value: test.start}},
+				{Type: OperationTypeAdd, Target: "characteristics.might", ValueRef: ValueRef{Type: ValueRefTypeInt, Value: test.add}},
+			}, nil)
+
+			sheet, err := resolveTest(reference, test.level, nil)
+			if err != nil {
+				t.Fatalf("failed to resolve: %s", err)
+			}
+			if sheet.Characteristics.Might != test.want {
+				t.Errorf("might is %d, want %d", sheet.Characteristics.Might, test.want)
+			}
+		})
+	}
+}
+
+// a feature like Revitalizing Ritual adds to the recovery value once it has
+// been derived from max stamina
+func TestRecoveryValueModifier(t *testing.T) {
+	reference := testReference([]Operation{
+		{Type: OperationTypeAddFeature, Target: FeaturesValueName, ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: "ritual", RefIDType: RefIDTypeFeature}},
+	}, nil)
+	reference.Features = map[string]Feature{
+		"ritual": {ID: "ritual", Name: "Ritual", Choices: []Choice{{
+			ID:   "ritual",
+			Type: ChoiceTypeOptionSelect,
+			Options: []Option{
+				{ID: "self", Operations: []Operation{
+					{Type: OperationTypeAdd, Target: "health.recovery_value", ValueRef: ValueRef{Type: ValueRefTypeID, Value: LevelValueName}},
+				}},
+				{ID: "ally"},
+			},
+		}}},
+	}
+
+	tests := []struct {
+		name   string
+		option string
+		want   int
+	}{
+		{name: "granted to self", option: "self", want: 21/3 + 3},
+		{name: "granted to an ally", option: "ally", want: 21 / 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sheet, err := resolveTest(reference, 3, map[string]Decision{
+				"ritual": {ChoiceID: "ritual", OptionID: test.option},
+			})
+			if err != nil {
+				t.Fatalf("failed to resolve: %s", err)
+			}
+			if sheet.Health.RecoveryValue != test.want {
+				t.Errorf("recovery value is %d, want %d", sheet.Health.RecoveryValue, test.want)
+			}
+			if sheet.Health.WindedValue != 21/2 {
+				t.Errorf("winded value is %d, want %d", sheet.Health.WindedValue, 21/2)
+			}
+		})
+	}
+}
+
+// Inner Light is granted by the sun domain and adds its saving throw bonus
+// only when the hero picks themselves
+func TestSaveBonusFromDomainFeature(t *testing.T) {
+	hasSun := Assertion{Type: AssertionTypeRefArray, RefType: RefIDTypeDomain, Values: []ValueRef{{Type: ValueRefTypeString, Value: "sun"}}}
+	reference := testReference([]Operation{
+		{Type: OperationTypeAddFeature, Target: FeaturesValueName, ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: "inner_light", RefIDType: RefIDTypeFeature}, Prereqs: []Assertion{hasSun}},
+	}, []Choice{
+		{ID: "domain", Type: ChoiceTypeRefSelect, RefType: RefIDTypeDomain},
+	})
+	reference.Domains = map[string]Domain{
+		"sun": {ID: "sun", Name: "Sun"},
+		"war": {ID: "war", Name: "War"},
+	}
+	reference.Features = map[string]Feature{
+		"inner_light": {ID: "inner_light", Name: "Inner Light", Choices: []Choice{{
+			ID:   "inner_light",
+			Type: ChoiceTypeOptionSelect,
+			Options: []Option{
+				{ID: "self", Operations: []Operation{
+					{Type: OperationTypeSet, Target: "saves.bonuses.inner_light", ValueRef: ValueRef{Type: ValueRefTypeInt, Value: 1}},
+				}},
+				{ID: "ally"},
+			},
+		}}},
+	}
+
+	tests := []struct {
+		name    string
+		domain  string
+		option  string
+		bonuses map[string]int
+	}{
+		{name: "sun domain granted to self", domain: "sun", option: "self", bonuses: map[string]int{"inner_light": 1}},
+		{name: "sun domain granted to an ally", domain: "sun", option: "ally"},
+		{name: "another domain", domain: "war", option: "self"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sheet, err := resolveTest(reference, 1, map[string]Decision{
+				"domain":      {ChoiceID: "domain", RefID: test.domain},
+				"inner_light": {ChoiceID: "inner_light", OptionID: test.option},
+			})
+			if err != nil {
+				t.Fatalf("failed to resolve: %s", err)
+			}
+			if sheet.Saves.Target != SaveTargetBase {
+				t.Errorf("save target is %d, want %d", sheet.Saves.Target, SaveTargetBase)
+			}
+			if len(sheet.Saves.Bonuses) != len(test.bonuses) {
+				t.Fatalf("save bonuses are %v, want %v", sheet.Saves.Bonuses, test.bonuses)
+			}
+			for source, bonus := range test.bonuses {
+				if sheet.Saves.Bonuses[source] != bonus {
+					t.Errorf("save bonuses are %v, want %v", sheet.Saves.Bonuses, test.bonuses)
+				}
+			}
+		})
+	}
+}
+
+// Oracular Visions is granted by the fate domain and gives the fate resource
+func TestResourceFromDomainFeature(t *testing.T) {
+	hasFate := Assertion{Type: AssertionTypeRefArray, RefType: RefIDTypeDomain, Values: []ValueRef{{Type: ValueRefTypeString, Value: "fate"}}}
+	reference := testReference([]Operation{
+		{Type: OperationTypeAddFeature, Target: FeaturesValueName, ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: "oracular_visions", RefIDType: RefIDTypeFeature}, Prereqs: []Assertion{hasFate}},
+	}, []Choice{
+		{ID: "domain", Type: ChoiceTypeRefSelect, RefType: RefIDTypeDomain},
+	})
+	reference.Domains = map[string]Domain{
+		"fate": {ID: "fate", Name: "Fate"},
+		"war":  {ID: "war", Name: "War"},
+	}
+	reference.Features = map[string]Feature{
+		"oracular_visions": {ID: "oracular_visions", Name: "Oracular Visions", Operations: []Operation{
+			{Type: OperationTypeGrantResource, Target: ResourcesValueName, ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: "fate", RefIDType: RefIDTypeResource}},
+		}},
+	}
+	reference.Resources = map[string]Resource{
+		"fate": {ID: "fate", Name: "Fate", Reset: "respite"},
+	}
+
+	tests := []struct {
+		domain    string
+		resources []string
+	}{
+		{domain: "fate", resources: []string{"fate"}},
+		{domain: "war"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.domain, func(t *testing.T) {
+			sheet, err := resolveTest(reference, 1, map[string]Decision{
+				"domain": {ChoiceID: "domain", RefID: test.domain},
+			})
+			if err != nil {
+				t.Fatalf("failed to resolve: %s", err)
+			}
+			if !slices.Equal(sheet.Resources, test.resources) {
+				t.Errorf("resources are %v, want %v", sheet.Resources, test.resources)
+			}
+		})
+	}
+}
+
+// a kit's damage bonus grows with the hero's echelon, and the 4th echelon keeps
+// the 3rd echelon's bonus
+func TestKitDamageBonus(t *testing.T) {
+	reference := testReference([]Operation{
+		{Type: OperationTypeAddKit, Target: KitsValueName, ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: "bow", RefIDType: RefIDTypeKit}},
+	}, nil)
+	reference.Kits = map[string]Kit{
+		"bow": {ID: "bow", Name: "Bow", Bonuses: KitBonuses{
+			MeleeDamageBonus:  KitDamageBonus{TierI: 1, TierII: 1, TierIII: 1},
+			RangedDamageBonus: KitDamageBonus{TierI: 1, TierII: 2, TierIII: 3},
+		}},
+	}
+
+	tests := []struct {
+		level int
+		want  model.DamageBonuses
+	}{
+		{level: 1, want: model.DamageBonuses{Melee: 1, Ranged: 1}},
+		{level: 4, want: model.DamageBonuses{Melee: 1, Ranged: 2}},
+		{level: 7, want: model.DamageBonuses{Melee: 1, Ranged: 3}},
+		{level: 10, want: model.DamageBonuses{Melee: 1, Ranged: 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("level %d", test.level), func(t *testing.T) {
+			sheet, err := resolveTest(reference, test.level, nil)
+			if err != nil {
+				t.Fatalf("failed to resolve: %s", err)
+			}
+			if sheet.DamageBonuses != test.want {
+				t.Errorf("damage bonuses are %+v, want %+v", sheet.DamageBonuses, test.want)
+			}
+		})
+	}
+}
+
+// a ref select can be limited to a few IDs, such as the abilities a class
+// offers
+func TestRefSelectRefIDs(t *testing.T) {
+	reference := testReference(nil, []Choice{
+		{ID: "triggered", Type: ChoiceTypeRefSelect, RefType: RefIDTypeAbility, RefIDs: []string{"ward"}},
+	})
+	reference.Abilities = map[string]Ability{
+		"ward":  {ID: "ward", Name: "Ward"},
+		"smite": {ID: "smite", Name: "Smite"},
+	}
+
+	sheet, err := resolveTest(reference, 1, map[string]Decision{
+		"triggered": {ChoiceID: "triggered", RefID: "ward"},
+	})
+	if err != nil {
+		t.Fatalf("failed to resolve: %s", err)
+	}
+	if !slices.Equal(sheet.Abilities, []string{"ward"}) {
+		t.Errorf("abilities are %v, want [ward]", sheet.Abilities)
+	}
+
+	_, err = resolveTest(reference, 1, map[string]Decision{
+		"triggered": {ChoiceID: "triggered", RefID: "smite"},
+	})
+	expectError(t, err, "is not one of")
+}
+
+// a character leveled by XP lists the choices of every level earned but not
+// yet taken, including nested choices, unless their prereqs aren't met
+func TestLevelUp(t *testing.T) {
+	hasSun := Assertion{Type: AssertionTypeRefArray, RefType: RefIDTypeDomain, Values: []ValueRef{{Type: ValueRefTypeString, Value: "sun"}}}
+	reference := testReference(nil, []Choice{
+		{ID: "domain", Type: ChoiceTypeRefSelect, RefType: RefIDTypeDomain},
+	})
+	reference.Domains = map[string]Domain{
+		"sun": {ID: "sun", Name: "Sun"},
+		"war": {ID: "war", Name: "War"},
+	}
+	reference.Classes[testClassID].Levels[2] = ClassLevel{Choices: []Choice{
+		{ID: "perk", Type: ChoiceTypeOptionSelect, Options: []Option{
+			{ID: "skilled", Choices: []Choice{{ID: "perk_skill", Type: ChoiceTypeRefSelect, RefType: RefIDTypeSkill}}},
+		}},
+		{ID: "sun_blessing", Type: ChoiceTypeOptionSelect, Prereqs: []Assertion{hasSun}, Options: []Option{
+			{ID: "light", Choices: []Choice{{ID: "sun_light", Type: ChoiceTypeRefSelect, RefType: RefIDTypeSkill}}},
+		}},
+	}}
+	reference.Classes[testClassID].Levels[3] = ClassLevel{Choices: []Choice{
+		{ID: "level_three", Type: ChoiceTypeRefSelect, RefType: RefIDTypeSkill},
+	}}
+	reference.Leveling = Leveling{XPThresholds: map[int]int{1: 0, 2: 16, 3: 32}}
+
+	tests := []struct {
+		name     string
+		leveling string
+		level    int
+		xp       int
+		domain   string
+		want     *model.LevelUp
+		wantErr  string
+	}{
+		{name: "manual leveling ignores xp", leveling: model.LevelingManual, level: 1, xp: 100, domain: "war"},
+		{name: "leveling defaults to manual", level: 1, xp: 100, domain: "war"},
+		{name: "no level earned", leveling: model.LevelingXP, level: 1, xp: 15, domain: "war"},
+		{
+			name: "one level earned", leveling: model.LevelingXP, level: 1, xp: 16, domain: "war",
+			want: &model.LevelUp{Level: 2, Choices: []string{"perk", "perk_skill"}},
+		},
+		{
+			name: "choices meeting their prereqs", leveling: model.LevelingXP, level: 1, xp: 16, domain: "sun",
+			want: &model.LevelUp{Level: 2, Choices: []string{"perk", "perk_skill", "sun_blessing", "sun_light"}},
+		},
+		{
+			name: "several levels earned", leveling: model.LevelingXP, level: 1, xp: 40, domain: "war",
+			want: &model.LevelUp{Level: 3, Choices: []string{"perk", "perk_skill", "level_three"}},
+		},
+		{name: "level higher than earned", leveling: model.LevelingXP, level: 2, xp: 15, domain: "war", wantErr: "level 2 is higher than level 1 earned with 15 xp"},
+		{name: "unknown leveling", leveling: "milestone", level: 1, domain: "war", wantErr: "invalid leveling"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			character := model.Character{ID: "test", ClassID: testClassID, Level: test.level, XP: test.xp, Leveling: test.leveling}
+			sheet, err := NewResolver(character, map[string]Decision{
+				"domain": {ChoiceID: "domain", RefID: test.domain},
+			}, reference).Resolve()
+			if test.wantErr != "" {
+				expectError(t, err, test.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to resolve: %s", err)
+			}
+
+			if test.want == nil {
+				if sheet.LevelUp != nil {
+					t.Errorf("level up is %+v, want none", sheet.LevelUp)
+				}
+				return
+			}
+			if sheet.LevelUp == nil {
+				t.Fatalf("no level up, want %+v", test.want)
+			}
+			if sheet.LevelUp.Level != test.want.Level || !slices.Equal(sheet.LevelUp.Choices, test.want.Choices) {
+				t.Errorf("level up is %+v, want %+v", sheet.LevelUp, test.want)
+			}
+		})
+	}
+}
+
+// a feature like Gods' Library grants a whole skill group, and a skill ref
+// select can be limited to some groups
+func TestSkillGroups(t *testing.T) {
+	reference := testReference([]Operation{
+		{Type: OperationTypeAddFeature, Target: FeaturesValueName, ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: "library", RefIDType: RefIDTypeFeature}},
+	}, []Choice{
+		{ID: "skill", Type: ChoiceTypeRefSelect, RefType: RefIDTypeSkill, RefGroups: []string{"lore", "crafting"}},
+	})
+	reference.Features = map[string]Feature{
+		"library": {ID: "library", Name: "Library", Operations: []Operation{
+			{Type: OperationTypeAddSkillGroup, Target: SkillsValueName, ValueRef: ValueRef{Type: ValueRefTypeRefID, Value: "lore", RefIDType: RefIDTypeSkillGroup}},
+		}},
+	}
+	reference.SkillGroups = map[string]SkillGroup{
+		"crafting": {ID: "crafting", Name: "Crafting"},
+		"lore":     {ID: "lore", Name: "Lore"},
+		"intrigue": {ID: "intrigue", Name: "Intrigue"},
+	}
+	reference.Skills = map[string]Skill{
+		"history":    {ID: "history", Name: "History", Group: "lore"},
+		"magic":      {ID: "magic", Name: "Magic", Group: "lore"},
+		"cooking":    {ID: "cooking", Name: "Cooking", Group: "crafting"},
+		"pickpocket": {ID: "pickpocket", Name: "Pickpocket", Group: "intrigue"},
+	}
+
+	tests := []struct {
+		name    string
+		skill   string
+		want    []string
+		wantErr string
+	}{
+		{name: "skill from another allowed group", skill: "cooking", want: []string{"cooking", "history", "magic"}},
+		{name: "skill already in the granted group", skill: "magic", want: []string{"history", "magic"}},
+		{name: "skill from a group not offered", skill: "pickpocket", wantErr: "is not in groups"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sheet, err := resolveTest(reference, 1, map[string]Decision{
+				"skill": {ChoiceID: "skill", RefID: test.skill},
+			})
+			if test.wantErr != "" {
+				expectError(t, err, test.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to resolve: %s", err)
+			}
+
+			skills := slices.Sorted(slices.Values(sheet.Skills))
+			if !slices.Equal(skills, test.want) {
+				t.Errorf("skills are %v, want %v", skills, test.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package rules
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type Trace struct {
 	trace []any
@@ -22,3 +25,16 @@ func (t *Trace) String() string {
 
 	return result
 }
+
+// Node returns the innermost node being evaluated, or an empty string if there
+// is none
+func (t *Trace) Node() string {
+	for i := len(t.trace) - 1; i >= 0; i-- {
+		value, ok := t.trace[i].(string)
+		if node, found := strings.CutPrefix(value, "node:"); ok && found {
+			return node
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,192 @@
+package rules
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/JamisonHubbard/dsbeyond/model"
+)
+
+// Validate checks the references between entities in the Reference, returning
+// every problem found
+func (r *Reference) Validate() []error {
+	var errs []error
+
+	// every skill must belong to a known skill group
+	for _, skillID := range sortedIDs(r.Skills) {
+		skill := r.Skills[skillID]
+		if _, ok := r.SkillGroups[skill.Group]; !ok {
+			errs = append(errs, fmt.Errorf("skill \"%s\" has unknown skill group \"%s\"", skillID, skill.Group))
+		}
+	}
+
+	// features can only supersede features that exist
+	for _, featureID := range sortedIDs(r.Features) {
+		for _, supersededID := range r.Features[featureID].Supersedes {
+			if _, ok := r.Features[supersededID]; !ok {
+				errs = append(errs, fmt.Errorf("feature \"%s\" supersedes unknown feature \"%s\"", featureID, supersededID))
+			}
+			if supersededID == featureID {
+				errs = append(errs, fmt.Errorf("feature \"%s\" supersedes itself", featureID))
+			}
+		}
+	}
+
+	// resources can only be spent as heroic resources that exist
+	for _, resourceID := range sortedIDs(r.Resources) {
+		spendAs := r.Resources[resourceID].SpendAs
+		if _, ok := r.HeroicResources[spendAs]; spendAs != "" && !ok {
+			errs = append(errs, fmt.Errorf("resource \"%s\" is spent as unknown heroic resource \"%s\"", resourceID, spendAs))
+		}
+	}
+
+	// XP thresholds must cover every level, increasing with each one
+	if thresholds := r.Leveling.XPThresholds; len(thresholds) > 0 {
+		for level := 1; level <= MaxLevel; level++ {
+			xp, ok := thresholds[level]
+			switch {
+			case !ok:
+				errs = append(errs, fmt.Errorf("level %d has no xp threshold", level))
+			case level == 1 && xp != 0:
+				errs = append(errs, fmt.Errorf("level 1 has xp threshold %d, expected 0", xp))
+			case level > 1 && xp <= thresholds[level-1]:
+				errs = append(errs, fmt.Errorf("level %d has xp threshold %d, which isn't above level %d", level, xp, level-1))
+			}
+		}
+		for _, level := range slices.Sorted(maps.Keys(thresholds)) {
+			if level < 1 || level > MaxLevel {
+				errs = append(errs, fmt.Errorf("xp threshold for level %d is outside levels 1 to %d", level, MaxLevel))
+			}
+		}
+	}
+
+	// a class with a class sheet must declare the same class values as it,
+	// while a class without one keeps its class values as a plain class block
+	for _, classID := range sortedIDs(r.Classes) {
+		newSheet, ok := model.ClassSheets[classID]
+		if !ok {
+			continue
+		}
+
+		fields := classSheetFields(reflect.TypeOf(newSheet()).Elem())
+		extension := r.Classes[classID].Extension
+		for _, name := range sortedIDs(extension) {
+			fieldType, ok := fields[name]
+			if !ok {
+				errs = append(errs, fmt.Errorf("class \"%s\" declares class value \"%s\" missing from its class sheet", classID, name))
+				continue
+			}
+			if fieldType != extension[name] {
+				errs = append(errs, fmt.Errorf("class \"%s\" declares class value \"%s\" as %s, but its class sheet has %s", classID, name, extension[name], fieldType))
+			}
+		}
+		for _, name := range sortedIDs(fields) {
+			if _, ok := extension[name]; !ok {
+				errs = append(errs, fmt.Errorf("class \"%s\" does not declare class value \"%s\" from its class sheet", classID, name))
+			}
+		}
+	}
+
+	return errs
+}
+
+// ValidateDecisions checks the decisions made for a character against the
+// choices of their class and background, returning every problem found
+func (r *Reference) ValidateDecisions(character model.Character, decisions map[string]Decision) []error {
+	var errs []error
+
+	class, ok := r.Classes[character.ClassID]
+	if !ok {
+		return []error{fmt.Errorf("class \"%s\" not found", character.ClassID)}
+	}
+
+	var choices []Choice
+	for _, level := range slices.Sorted(maps.Keys(class.Levels)) {
+		choices = append(choices, class.Levels[level].Choices...)
+	}
+	choices = append(choices, r.Ancestries[character.AncestryID].Choices...)
+	choices = append(choices, r.Cultures[character.CultureID].Choices...)
+	choices = append(choices, r.Careers[character.CareerID].Choices...)
+
+	// a point buy can only buy each option once
+	walkChoices(choices, func(choice *Choice) {
+		if choice.Type != ChoiceTypePointBuy {
+			return
+		}
+		optionIDs := decisions[choice.ID].OptionIDs
+		for i, optionID := range optionIDs {
+			if slices.Contains(optionIDs[:i], optionID) {
+				errs = append(errs, fmt.Errorf("option \"%s\" for choice \"%s\" is picked more than once", optionID, choice.ID))
+			}
+		}
+	})
+
+	// a domain can only be chosen once, e.g. a character with two domains
+	// needs two different ones
+	chosenDomains := make(map[string]string)
+	walkChoices(choices, func(choice *Choice) {
+		if choice.Type != ChoiceTypeRefSelect || choice.RefType != RefIDTypeDomain {
+			return
+		}
+		domainID := decisions[choice.ID].RefID
+		if domainID == "" {
+			return
+		}
+		if previous, ok := chosenDomains[domainID]; ok {
+			errs = append(errs, fmt.Errorf("domain \"%s\" is chosen by both \"%s\" and \"%s\"", domainID, previous, choice.ID))
+			return
+		}
+		chosenDomains[domainID] = choice.ID
+	})
+
+	return errs
+}
+
+// walkChoices calls fn for each choice, followed by the choices nested in its
+// options
+func walkChoices(choices []Choice, fn func(choice *Choice)) {
+	for i := range choices {
+		fn(&choices[i])
+		for _, option := range choices[i].Options {
+			walkChoices(option.Choices, fn)
+		}
+	}
+}
+
+// classSheetFields maps the JSON name of each field in a class sheet to the
+// class value type it holds
+func classSheetFields(t reflect.Type) map[string]string {
+	fields := make(map[string]string)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		switch {
+		case field.Type.Kind() == reflect.Int:
+			fields[name] = ClassValueTypeInt
+		case field.Type.Kind() == reflect.String:
+			fields[name] = ClassValueTypeString
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+			fields[name] = ClassValueTypeStringList
+		default:
+			fields[name] = field.Type.String()
+		}
+	}
+	return fields
+}
+
+func sortedIDs[T any](entities map[string]T) []string {
+	ids := make([]string, 0, len(entities))
+	for id := range entities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,181 @@
+package schema
+
+import "github.com/JamisonHubbard/dsbeyond/rules"
+
+var refIDTypes = []string{
+	rules.RefIDTypeAbility,
+	rules.RefIDTypeAbilityModifier,
+	rules.RefIDTypeDomain,
+	rules.RefIDTypeFeature,
+	rules.RefIDTypeHeroicResource,
+	rules.RefIDTypeKit,
+	rules.RefIDTypeLanguage,
+	rules.RefIDTypeResource,
+	rules.RefIDTypeSkill,
+	rules.RefIDTypeSkillGroup,
+	rules.RefIDTypeTitle,
+	rules.RefIDTypeTreasure,
+}
+
+var operationTypes = []string{
+	rules.OperationTypeSet,
+	rules.OperationTypeAdd,
+	rules.OperationTypeSubtract,
+	rules.OperationTypeAddAbility,
+	rules.OperationTypeAddBane,
+	rules.OperationTypeAddDomain,
+	rules.OperationTypeAddEdge,
+	rules.OperationTypeAddFeature,
+	rules.OperationTypeAddKit,
+	rules.OperationTypeAddLanguage,
+	rules.OperationTypeAddSkill,
+	rules.OperationTypeAddSkillGroup,
+	rules.OperationTypeAddTitle,
+	rules.OperationTypeGrantImmunity,
+	rules.OperationTypeGrantMovement,
+	rules.OperationTypeGrantResource,
+	rules.OperationTypeGrantWeakness,
+	rules.OperationTypeModifyAbility,
+}
+
+var damageTypes = append([]string{rules.DamageTypeUntyped}, rules.DamageTypes...)
+
+var sectionTypes = []string{
+	rules.AbilitySectionTypeText,
+	rules.AbilitySectionTypeBulletedText,
+	rules.AbilitySectionTypePowerRoll,
+}
+
+var resetTypes = []string{
+	rules.ResetTypeEncounterEnd,
+	rules.ResetTypeRespite,
+	rules.ResetTypeNever,
+}
+
+// Enums maps a "Type.json_key" property to the constants it may hold
+// NOTE: keep this in sync with the constants in the rules package
+var Enums = map[string][]string{
+	"ValueRef.type": {
+		rules.ValueRefTypeExpression,
+		rules.ValueRefTypeID,
+		rules.ValueRefTypeInt,
+		rules.ValueRefTypeLevelTable,
+		rules.ValueRefTypeRefID,
+		rules.ValueRefTypeString,
+	},
+	"ValueRef.ref_type": refIDTypes,
+	"Operation.type":    operationTypes,
+	"Assertion.type": {
+		rules.AssertionTypeValue,
+		rules.AssertionTypeRefArray,
+		rules.AssertionTypeComparison,
+	},
+	"Assertion.ref_type": refIDTypes,
+	"Assertion.comparison_type": {
+		rules.ComparisonTypeLessThan,
+		rules.ComparisonTypeGreaterThan,
+	},
+	"Expression.type": {
+		rules.ExprTypeAdd,
+		rules.ExprTypeSubtract,
+		rules.ExprTypeDivide,
+		rules.ExprTypeMax,
+	},
+	"LevelTable.scale": {
+		rules.LevelTableScaleLevel,
+		rules.LevelTableScaleEchelon,
+	},
+	"Choice.type": {
+		rules.ChoiceTypeOptionSelect,
+		rules.ChoiceTypeRefSelect,
+		rules.ChoiceTypeInput,
+		rules.ChoiceTypePointBuy,
+		rules.ChoiceTypeCharacteristicArray,
+	},
+	"HeroicResource.reset": resetTypes,
+	"Resource.reset":       resetTypes,
+	"Treasure.type": {
+		rules.TreasureTypeConsumable,
+		rules.TreasureTypeTrinket,
+		rules.TreasureTypeLeveledArmor,
+		rules.TreasureTypeLeveledImplement,
+		rules.TreasureTypeLeveledWeapon,
+		rules.TreasureTypeArtifact,
+	},
+	"Class.extension": {
+		rules.ClassValueTypeInt,
+		rules.ClassValueTypeString,
+		rules.ClassValueTypeStringList,
+	},
+	"Choice.ref_type":        refIDTypes,
+	"Choice.operation_type":  operationTypes,
+	"Choice.characteristics": rules.Characteristics,
+	"Feature.type": {
+		rules.FeatureTypeBasic,
+		rules.FeatureTypeAncestryTrait,
+		rules.FeatureTypePerk,
+	},
+	"FeatureSection.type": {
+		rules.FeatureSectionTypeText,
+		rules.FeatureSectionTypeBulletedText,
+	},
+	"Ability.type": {
+		rules.AbilityTypeStandard,
+		rules.AbilityTypeDomain,
+		rules.AbilityTypeHeroic,
+		rules.AbilityTypeSignature,
+	},
+	"Ability.action_type": {
+		rules.ActionTypeMain,
+		rules.ActionTypeManeuver,
+		rules.ActionTypeTriggered,
+		rules.ActionTypeFreeTriggered,
+		rules.ActionTypeMovement,
+	},
+	"Range.type": {
+		rules.RangeTypeDistance,
+		rules.RangeTypeArea,
+	},
+	"Range.subtype": {
+		rules.DistanceTypeMelee,
+		rules.DistanceTypeRanged,
+		rules.DistanceTypeMeleeOrRanged,
+		rules.DistanceTypeSelf,
+		rules.AreaTypeAura,
+		rules.AreaTypeBurst,
+		rules.AreaTypeCube,
+		rules.AreaTypeLine,
+		rules.AreaTypeWall,
+	},
+	"AbilitySection.type": sectionTypes,
+	"AbilityRollModifier.type": {
+		rules.AbilityRollModifierTypeSingle,
+		rules.AbilityRollModifierTypeOr,
+	},
+	"AbilityRollResult.damage_type": damageTypes,
+	"AbilityModifier.type": {
+		rules.AbilityModifierTypeAppend,
+	},
+	"KitEquipment.armor_type": {
+		rules.ArmorTypeNoArmor,
+		rules.ArmorTypeLight,
+		rules.ArmorTypeMedium,
+		rules.ArmorTypeHeavy,
+	},
+	"KitWeapon.type": {
+		rules.WeaponTypeBow,
+		rules.WeaponTypeEnsnaring,
+		rules.WeaponTypeLight,
+		rules.WeaponTypeMedium,
+		rules.WeaponTypeHeavy,
+		rules.WeaponTypePolearm,
+		rules.WeaponTypeUnarmed,
+		rules.WeaponTypeWhip,
+	},
+	"KitWeapon.amount": {
+		rules.WeaponAmountOne,
+		rules.WeaponAmountOneOrTwo,
+		rules.WeaponAmountOnly,
+		rules.WeaponAmountSeveral,
+	},
+}
@@ -0,0 +1,314 @@
+// Package schema generates JSON Schema documents for the reference data types
+// and validates hand-written data files against them
+package schema
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/JamisonHubbard/dsbeyond/rules"
+)
+
+const (
+	Draft   = "https://json-schema.org/draft/2020-12/schema"
+	BaseURI = "https://github.com/JamisonHubbard/dsbeyond/schemas/"
+
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+)
+
+// A Schema is the subset of JSON Schema (draft 2020-12) used to describe the
+// reference data
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	PropertyNames        *Schema            `json:"propertyNames,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Types lists the reference data types that a schema document is generated
+// for, keyed by the document name
+var Types = map[string]reflect.Type{
+	"Ancestry":           reflect.TypeFor[rules.Ancestry](),
+	"Career":             reflect.TypeFor[rules.Career](),
+	"Class":              reflect.TypeFor[rules.Class](),
+	"Complication":       reflect.TypeFor[rules.Complication](),
+	"ComplicationEffect": reflect.TypeFor[rules.ComplicationEffect](),
+	"Culture":            reflect.TypeFor[rules.Culture](),
+	"ClassLevel":         reflect.TypeFor[rules.ClassLevel](),
+	"Choice":             reflect.TypeFor[rules.Choice](),
+	"Option":             reflect.TypeFor[rules.Option](),
+	"Operation":          reflect.TypeFor[rules.Operation](),
+	"Assertion":          reflect.TypeFor[rules.Assertion](),
+	"ValueRef":           reflect.TypeFor[rules.ValueRef](),
+	"Expression":         reflect.TypeFor[rules.Expression](),
+	"LevelTable":         reflect.TypeFor[rules.LevelTable](),
+	"Ability":            reflect.TypeFor[rules.Ability](),
+	"Feature":            reflect.TypeFor[rules.Feature](),
+	"HeroicResource":     reflect.TypeFor[rules.HeroicResource](),
+	"Kit":                reflect.TypeFor[rules.Kit](),
+	"Leveling":           reflect.TypeFor[rules.Leveling](),
+	"Language":           reflect.TypeFor[rules.Language](),
+	"Resource":           reflect.TypeFor[rules.Resource](),
+	"Skill":              reflect.TypeFor[rules.Skill](),
+	"SkillGroup":         reflect.TypeFor[rules.SkillGroup](),
+	"Title":              reflect.TypeFor[rules.Title](),
+	"Treasure":           reflect.TypeFor[rules.Treasure](),
+	"Domain":             reflect.TypeFor[rules.Domain](),
+}
+
+// required lists the properties that must be present for each type, the rest
+// are optional since the loaders fall back to zero values
+var required = map[string][]string{
+	"Ancestry":        {"id", "name"},
+	"Career":          {"id", "name"},
+	"Class":           {"id", "name", "levels"},
+	"Complication":    {"id", "name", "benefit", "drawback"},
+	"Culture":         {"id", "name"},
+	"Choice":          {"id", "type"},
+	"Option":          {"id"},
+	"Operation":       {"type", "target", "value_ref"},
+	"Assertion":       {"type"},
+	"ValueRef":        {"type", "value"},
+	"Expression":      {"type", "args"},
+	"LevelTable":      {"entries"},
+	"LevelTableEntry": {"threshold", "value"},
+	"Ability":         {"id", "name"},
+	"Feature":         {"id", "name"},
+	"HeroicResource":  {"id", "name", "per_turn", "reset"},
+	"Kit":             {"id", "name"},
+	"Language":        {"id", "name"},
+	"Leveling":        {"xp_thresholds"},
+	"Resource":        {"id", "name", "reset"},
+	"Skill":           {"id", "name", "group"},
+	"SkillGroup":      {"id", "name"},
+	"Title":           {"id", "name", "benefits"},
+	"Treasure":        {"id", "name", "type"},
+	"Domain":          {"id", "name"},
+}
+
+// Generate builds the schema document for each entry in Types
+func Generate() map[string]*Schema {
+	documents := make(map[string]*Schema)
+	for name, t := range Types {
+		documents[name] = Document(name, t)
+	}
+	return documents
+}
+
+// Document builds a standalone schema document for the given type, with every
+// type it references included in $defs
+func Document(name string, t reflect.Type) *Schema {
+	g := generator{defs: make(map[string]*Schema)}
+	g.define(t)
+
+	return &Schema{
+		Schema: Draft,
+		ID:     BaseURI + FileName(name),
+		Title:  name,
+		Ref:    defRef(t.Name()),
+		Defs:   g.defs,
+	}
+}
+
+// ArrayDocument builds a schema document for a file containing a JSON array
+// of the given type
+func ArrayDocument(name string, t reflect.Type) *Schema {
+	document := Document(name, t)
+	document.Ref = ""
+	document.Type = TypeArray
+	document.Items = &Schema{Ref: defRef(t.Name())}
+	return document
+}
+
+// FileName returns the file name a schema document is written to
+func FileName(name string) string {
+	return name + ".schema.json"
+}
+
+func defRef(name string) string {
+	return "#/$defs/" + name
+}
+
+type generator struct {
+	defs map[string]*Schema
+}
+
+// define adds the named struct type to the generator's definitions, along
+// with every type reachable from it
+func (g *generator) define(t reflect.Type) {
+	name := t.Name()
+	if _, ok := g.defs[name]; ok {
+		return
+	}
+
+	// types with a custom unmarshaller have a hand-written schema
+	switch t {
+	case reflect.TypeFor[rules.ValueRef]():
+		g.defs[name] = nil
+		g.defineValueRef()
+		return
+	case reflect.TypeFor[rules.Expression]():
+		g.defs[name] = nil
+		g.defineExpression()
+		return
+	}
+
+	// reserve the name to allow recursive types
+	g.defs[name] = nil
+
+	schema := &Schema{
+		Type:                 TypeObject,
+		Properties:           make(map[string]*Schema),
+		Required:             required[name],
+		AdditionalProperties: false,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key := jsonName(field)
+		if key == "-" {
+			continue
+		}
+
+		property := g.schemaFor(field.Type)
+		if values, ok := Enums[name+"."+key]; ok {
+			// the enum of a map or list applies to its values
+			if elem, ok := property.AdditionalProperties.(*Schema); ok {
+				elem.Enum = enumValues(values)
+			} else if property.Items != nil {
+				property.Items.Enum = enumValues(values)
+			} else {
+				property.Enum = enumValues(values)
+			}
+		}
+		schema.Properties[key] = property
+	}
+
+	g.defs[name] = schema
+}
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: TypeString}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: TypeInteger}
+	case reflect.Bool:
+		return &Schema{Type: TypeBoolean}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: TypeArray, Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		schema := &Schema{Type: TypeObject, AdditionalProperties: g.schemaFor(t.Elem())}
+		if t.Key().Kind() != reflect.String {
+			schema.PropertyNames = &Schema{Pattern: "^-?[0-9]+$"}
+		}
+		return schema
+	case reflect.Pointer:
+		return g.schemaFor(t.Elem())
+	case reflect.Struct:
+		g.define(t)
+		return &Schema{Ref: defRef(t.Name())}
+	default:
+		// interfaces accept any value
+		return &Schema{}
+	}
+}
+
+// defineValueRef describes ValueRef, whose value depends on its type
+func (g *generator) defineValueRef() {
+	g.define(reflect.TypeFor[rules.Expression]())
+	g.define(reflect.TypeFor[rules.LevelTable]())
+
+	valueSchemas := map[string]*Schema{
+		rules.ValueRefTypeExpression: {Ref: defRef("Expression")},
+		rules.ValueRefTypeID:         {Type: TypeString},
+		rules.ValueRefTypeInt:        {Type: TypeInteger},
+		rules.ValueRefTypeLevelTable: {Ref: defRef("LevelTable")},
+		rules.ValueRefTypeRefID:      {Type: TypeString},
+		rules.ValueRefTypeString:     {Type: TypeString},
+	}
+
+	var branches []*Schema
+	for _, valueType := range sortedKeys(valueSchemas) {
+		branches = append(branches, &Schema{
+			Properties: map[string]*Schema{
+				"type":  {Enum: []any{valueType}},
+				"value": valueSchemas[valueType],
+			},
+		})
+	}
+
+	g.defs["ValueRef"] = &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"type":     {Type: TypeString, Enum: enumValues(Enums["ValueRef.type"])},
+			"value":    {},
+			"ref_type": {Type: TypeString, Enum: enumValues(Enums["ValueRef.ref_type"])},
+		},
+		Required:             required["ValueRef"],
+		AdditionalProperties: false,
+		OneOf:                branches,
+	}
+}
+
+// defineExpression describes Expression, whose args are ValueRefs
+func (g *generator) defineExpression() {
+	g.defs["Expression"] = &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"type": {Type: TypeString, Enum: enumValues(Enums["Expression.type"])},
+			"args": {Type: TypeArray, Items: &Schema{Ref: defRef("ValueRef")}},
+		},
+		Required:             required["Expression"],
+		AdditionalProperties: false,
+	}
+	g.define(reflect.TypeFor[rules.ValueRef]())
+}
+
+func jsonName(field reflect.StructField) string {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func enumValues(values []string) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,309 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// A DataFile describes where a reference data type is stored relative to the
+// data directory
+type DataFile struct {
+	Path   string
+	Type   string
+	Folder bool
+	Array  bool
+}
+
+// DataFiles mirrors the layout read by the reference loader
+var DataFiles = []DataFile{
+	{Path: "abilities", Type: "Ability", Folder: true, Array: true},
+	{Path: "ancestries", Type: "Ancestry", Folder: true},
+	{Path: "careers.json", Type: "Career", Array: true},
+	{Path: "classes", Type: "Class", Folder: true},
+	{Path: "complications.json", Type: "Complication", Array: true},
+	{Path: "cultures.json", Type: "Culture", Array: true},
+	{Path: "domains.json", Type: "Domain", Array: true},
+	{Path: "features", Type: "Feature", Folder: true, Array: true},
+	{Path: "heroic_resources.json", Type: "HeroicResource", Array: true},
+	{Path: "kits.json", Type: "Kit", Array: true},
+	{Path: "languages.json", Type: "Language", Array: true},
+	{Path: "leveling.json", Type: "Leveling"},
+	{Path: "resources.json", Type: "Resource", Array: true},
+	{Path: "skills.json", Type: "Skill", Array: true},
+	{Path: "skill_groups.json", Type: "SkillGroup", Array: true},
+	{Path: "titles.json", Type: "Title", Array: true},
+	{Path: "treasures.json", Type: "Treasure", Array: true},
+}
+
+// FileDocument returns the schema document that a file of this kind must
+// satisfy
+func (f DataFile) FileDocument() *Schema {
+	if f.Array {
+		return ArrayDocument(f.Type+"List", Types[f.Type])
+	}
+	return Document(f.Type, Types[f.Type])
+}
+
+// A ValidationError is a single schema violation within a data file
+type ValidationError struct {
+	File    string
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.File, e.Path, e.Message)
+}
+
+// ValidateDir checks every data file below root against its schema
+func ValidateDir(root string) ([]ValidationError, error) {
+	var errs []ValidationError
+
+	for _, dataFile := range DataFiles {
+		document := dataFile.FileDocument()
+
+		paths := []string{filepath.Join(root, dataFile.Path)}
+		if dataFile.Folder {
+			entries, err := os.ReadDir(paths[0])
+			if err != nil {
+				return nil, fmt.Errorf("failed to read directory %s: %w", paths[0], err)
+			}
+
+			paths = nil
+			for _, entry := range entries {
+				if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+					continue
+				}
+				paths = append(paths, filepath.Join(root, dataFile.Path, entry.Name()))
+			}
+		}
+
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+
+			fileErrs, err := ValidateJSON(document, data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			for _, fileErr := range fileErrs {
+				fileErr.File = path
+				errs = append(errs, fileErr)
+			}
+		}
+	}
+
+	return errs, nil
+}
+
+// ValidateJSON checks raw JSON against a schema document
+func ValidateJSON(document *Schema, data []byte) ([]ValidationError, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return Validate(document, value), nil
+}
+
+// Validate checks a decoded JSON value against a schema document. Numbers
+// must be decoded as json.Number.
+func Validate(document *Schema, value any) []ValidationError {
+	v := validator{root: document}
+	v.validate(document, value, "$")
+	return v.errs
+}
+
+type validator struct {
+	root *Schema
+	errs []ValidationError
+}
+
+func (v *validator) fail(path string, format string, args ...any) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(schema *Schema, value any, path string) {
+	if schema.Ref != "" {
+		resolved, ok := v.resolve(schema.Ref)
+		if !ok {
+			v.fail(path, "unresolved reference %s", schema.Ref)
+			return
+		}
+		v.validate(resolved, value, path)
+	}
+
+	if schema.Type != "" && !matchesType(schema.Type, value) {
+		v.fail(path, "expected %s, got %s", schema.Type, typeName(value))
+		return
+	}
+
+	if len(schema.Enum) > 0 {
+		if !isComparable(value) || !slices.Contains(schema.Enum, value) {
+			v.fail(path, "value %v is not one of %v", value, schema.Enum)
+		}
+	}
+
+	if schema.Pattern != "" {
+		if s, ok := value.(string); ok && !regexp.MustCompile(schema.Pattern).MatchString(s) {
+			v.fail(path, "value \"%s\" does not match %s", s, schema.Pattern)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		v.validateObject(schema, value, path)
+	case []any:
+		if schema.Items != nil {
+			for i, item := range value {
+				v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+
+	if len(schema.OneOf) > 0 {
+		v.validateOneOf(schema.OneOf, value, path)
+	}
+}
+
+func (v *validator) validateObject(schema *Schema, object map[string]any, path string) {
+	for _, key := range schema.Required {
+		if _, ok := object[key]; !ok {
+			v.fail(path, "missing required property \"%s\"", key)
+		}
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := path + "." + key
+
+		if schema.PropertyNames != nil {
+			v.validate(schema.PropertyNames, key, keyPath)
+		}
+
+		if property, ok := schema.Properties[key]; ok {
+			v.validate(property, object[key], keyPath)
+			continue
+		}
+
+		switch additional := schema.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				v.fail(path, "unknown property \"%s\"", key)
+			}
+		case *Schema:
+			v.validate(additional, object[key], keyPath)
+		}
+	}
+}
+
+// validateOneOf requires exactly one branch to match, reporting the errors of
+// the closest branch when none do
+func (v *validator) validateOneOf(branches []*Schema, value any, path string) {
+	var closest []ValidationError
+	matches := 0
+	for i, branch := range branches {
+		branchErrs := (&validator{root: v.root}).run(branch, value, path)
+		if len(branchErrs) == 0 {
+			matches++
+			continue
+		}
+		if i == 0 || len(branchErrs) < len(closest) {
+			closest = branchErrs
+		}
+	}
+
+	switch {
+	case matches == 0:
+		v.errs = append(v.errs, closest...)
+	case matches > 1:
+		v.fail(path, "value matches %d alternatives, expected exactly one", matches)
+	}
+}
+
+func (v *validator) run(schema *Schema, value any, path string) []ValidationError {
+	v.validate(schema, value, path)
+	return v.errs
+}
+
+func (v *validator) resolve(ref string) (*Schema, bool) {
+	name, ok := strings.CutPrefix(ref, "#/$defs/")
+	if !ok {
+		return nil, false
+	}
+	schema, ok := v.root.Defs[name]
+	return schema, ok && schema != nil
+}
+
+func matchesType(schemaType string, value any) bool {
+	switch schemaType {
+	case TypeObject:
+		_, ok := value.(map[string]any)
+		return ok
+	case TypeArray:
+		_, ok := value.([]any)
+		return ok
+	case TypeString:
+		_, ok := value.(string)
+		return ok
+	case TypeInteger:
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := number.Int64()
+		return err == nil
+	case TypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	default:
+		return true
+	}
+}
+
+// isComparable reports whether a decoded JSON value can be compared against
+// enum values
+func isComparable(value any) bool {
+	switch value.(type) {
+	case map[string]any, []any:
+		return false
+	default:
+		return true
+	}
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return TypeObject
+	case []any:
+		return TypeArray
+	case string:
+		return TypeString
+	case json.Number:
+		return "number"
+	case bool:
+		return TypeBoolean
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}